@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/LazarenkoA/Obfuscator-1C/obfuscator"
+	"github.com/pkg/errors"
+)
+
+type options struct {
+	in         string
+	out        string
+	preset     string
+	passes     string
+	config     string
+	modulePath string
+	dir        string
+	forms      string
+	metadata   string
+	kind       string
+	report     string
+	reportMD   string
+	hot        string
+	clients    string
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) (err error) {
+	var opt options
+	var conf obfuscator.Config
+
+	fs := flag.NewFlagSet("obfuscator", flag.ContinueOnError)
+	fs.StringVar(&opt.in, "in", "", "файл модуля (по умолчанию stdin)")
+	fs.StringVar(&opt.out, "out", "", "файл результата (по умолчанию stdout)")
+	fs.StringVar(&opt.preset, "preset", "", "пресет: "+presetNames())
+	fs.StringVar(&opt.passes, "passes", "", "порядок проходов через запятую")
+	fs.StringVar(&opt.config, "config", "", "файл настроек (yaml или json)")
+	fs.StringVar(&opt.modulePath, "path", "", "путь модуля для правил из файла настроек (по умолчанию -in)")
+	fs.StringVar(&opt.kind, "kind", "", "тип модуля (object, manager, form, common и т.д.), по умолчанию определяется по -path")
+	fs.StringVar(&opt.dir, "dir", "", "каталог выгрузки конфигурации, результат записывается в каталог -out")
+	fs.StringVar(&opt.forms, "form-handlers", string(obfuscator.HandlersPreserve), "обработчики из Form.xml: preserve или rename")
+	fs.StringVar(&opt.metadata, "metadata-handlers", string(obfuscator.HandlersPreserve), "обработчики подписок, регламентных заданий и сервисов: preserve или rename")
+	fs.StringVar(&opt.report, "report", "", "файл отчета об обфускации в формате json")
+	fs.StringVar(&opt.reportMD, "report-md", "", "файл сводного отчета в формате markdown")
+	fs.StringVar(&opt.hot, "hot", "", "файл со списком нагруженных методов, по одному имени в строке")
+	fs.BoolVar(&conf.Performance, "performance", false, "не применять к циклам и нагруженным методам преобразования, замедляющие выполнение")
+	fs.StringVar(&conf.Target.PlatformVersion, "platform", "", "минимальная версия платформы, например 8.3.10")
+	fs.StringVar(&conf.Target.CompatibilityMode, "compatibility", "", "режим совместимости конфигурации, например 8.3.10")
+	fs.BoolVar(&conf.Target.SafeMode, "safe-mode", false, "код выполняется в безопасном режиме, Выполнить()/Вычислить() не используются")
+	fs.StringVar(&opt.clients, "clients", "", "клиентские приложения через запятую: thin, thick, web, mobile")
+	fs.BoolVar(&conf.RepExpByTernary, "ternary", false, "заменять выражения тернарными операторами")
+	fs.BoolVar(&conf.RepLoopByGoto, "goto", false, "заменять циклы на Перейти")
+	fs.BoolVar(&conf.RepExpByEval, "eval", false, "прятать выражения в Выполнить() Вычислить()")
+	fs.BoolVar(&conf.HideString, "strings", false, "прятать строки")
+	fs.BoolVar(&conf.ChangeConditions, "conditions", false, "изменять условия")
+	fs.BoolVar(&conf.AppendGarbage, "garbage", false, "добавлять мусор")
+	fs.BoolVar(&conf.ExceptionFlow, "exceptions", false, "передавать результат условий через ВызватьИсключение")
+	fs.BoolVar(&conf.CallStackHell, "callstack", false, "прятать выражения за фейковыми функциями")
+	fs.BoolVar(&conf.RenameMethods, "rename", false, "переименовывать неэкспортные методы")
+	fs.StringVar((*string)(&conf.Regions), "regions", "", "области: keep, remove или decoy")
+	fs.BoolVar(&conf.RenameVariables, "rename-vars", false, "переименовывать неэкспортные переменные модуля")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// диагностики выводятся в stderr, ошибки в них завершают работу с ошибкой
+	var errorsCount atomic.Int64
+	logger := obfuscator.WriterLogger(os.Stderr)
+	conf.Logger = obfuscator.LoggerFunc(func(d obfuscator.Diagnostic) {
+		if d.Severity == obfuscator.SeverityError {
+			errorsCount.Add(1)
+		}
+		logger.Log(d)
+	})
+	defer func() {
+		if err == nil && errorsCount.Load() > 0 {
+			err = errors.Errorf("obfuscation finished with %d errors", errorsCount.Load())
+		}
+	}()
+
+	if opt.hot != "" {
+		if conf.HotMethods, err = readNames(opt.hot); err != nil {
+			return err
+		}
+	}
+
+	var fileConf *obfuscator.FileConfig
+	if opt.config != "" {
+		var err error
+		if fileConf, err = obfuscator.LoadConfigFile(opt.config); err != nil {
+			return err
+		}
+	}
+
+	if opt.dir != "" {
+		if opt.out == "" {
+			return errors.New("-out is required with -dir")
+		}
+
+		var reports []obfuscator.Report
+		err := obfuscator.ObfuscateDir(context.Background(), opt.dir, opt.out, obfuscator.BatchOptions{
+			ConfigFor: func(modulePath string) (obfuscator.Config, bool, error) {
+				return buildConfig(fs, opt, conf, fileConf, modulePath)
+			},
+			FormHandlers:     obfuscator.HandlersMode(opt.forms),
+			MetadataHandlers: obfuscator.HandlersMode(opt.metadata),
+			OnResult: func(result obfuscator.Result) {
+				reports = append(reports, result.Report)
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		return writeReports(opt, obfuscator.Summarize(reports), nil)
+	}
+
+	modulePath := opt.modulePath
+	if modulePath == "" {
+		modulePath = opt.in
+	}
+	kind, err := obfuscator.ParseModuleKind(opt.kind)
+	if err != nil {
+		return err
+	}
+
+	result, enabled, err := buildConfig(fs, opt, conf, fileConf, modulePath)
+	if err != nil {
+		return err
+	}
+
+	code, err := readInput(opt.in)
+	if err != nil {
+		return err
+	}
+
+	if !enabled {
+		return writeOutput(opt.out, code)
+	}
+
+	obf := obfuscator.NewObfuscatory(context.Background(), result)
+	obResult, err := obf.ObfuscateResult(code, obfuscator.ModuleInfo{Kind: kind, Path: modulePath})
+	if err != nil {
+		return errors.Wrap(err, "obfuscate error")
+	}
+
+	if err := writeOutput(opt.out, obResult.Code); err != nil {
+		return err
+	}
+
+	return writeReports(opt, obfuscator.Summarize([]obfuscator.Report{obResult.Report}), &obResult.Report)
+}
+
+// writeReports записывает отчеты, если они заданы флагами. В json для одного модуля пишется отчет модуля, для каталога - сводный
+func writeReports(opt options, summary obfuscator.Summary, module *obfuscator.Report) error {
+	if opt.report != "" {
+		var data []byte
+		var err error
+		if module != nil {
+			data, err = json.MarshalIndent(module, "", "  ")
+		} else {
+			data, err = json.MarshalIndent(summary, "", "  ")
+		}
+		if err != nil {
+			return errors.Wrap(err, "report error")
+		}
+		if err := writeOutput(opt.report, string(data)); err != nil {
+			return err
+		}
+	}
+
+	if opt.reportMD != "" {
+		return writeOutput(opt.reportMD, summary.Markdown())
+	}
+
+	return nil
+}
+
+// buildConfig берет настройки из пресета и файла настроек и переопределяет их флагами, которые явно указаны в командной строке.
+// Пресет из командной строки заменяет пресет файла, глобальные настройки и правила файла накладываются поверх него.
+// Если модуль исключен файлом настроек, возвращается false
+func buildConfig(fs *flag.FlagSet, opt options, flags obfuscator.Config, fileConf *obfuscator.FileConfig, modulePath string) (obfuscator.Config, bool, error) {
+	var conf obfuscator.Config
+	switch {
+	case fileConf != nil:
+		file := *fileConf
+		if opt.preset != "" {
+			file.Preset = obfuscator.Preset(opt.preset)
+		}
+
+		var enabled bool
+		var err error
+		if conf, enabled, err = file.ConfigFor(modulePath); err != nil || !enabled {
+			return conf, enabled, err
+		}
+	case opt.preset != "":
+		var err error
+		if conf, err = obfuscator.Preset(opt.preset).Config(); err != nil {
+			return conf, false, err
+		}
+	}
+
+	conf.Logger = flags.Logger
+	conf.HotMethods = append(append([]string{}, conf.HotMethods...), flags.HotMethods...)
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "ternary":
+			conf.RepExpByTernary = flags.RepExpByTernary
+		case "goto":
+			conf.RepLoopByGoto = flags.RepLoopByGoto
+		case "eval":
+			conf.RepExpByEval = flags.RepExpByEval
+		case "strings":
+			conf.HideString = flags.HideString
+		case "conditions":
+			conf.ChangeConditions = flags.ChangeConditions
+		case "garbage":
+			conf.AppendGarbage = flags.AppendGarbage
+		case "exceptions":
+			conf.ExceptionFlow = flags.ExceptionFlow
+		case "callstack":
+			conf.CallStackHell = flags.CallStackHell
+		case "rename":
+			conf.RenameMethods = flags.RenameMethods
+		case "rename-vars":
+			conf.RenameVariables = flags.RenameVariables
+		case "regions":
+			conf.Regions = flags.Regions
+		case "performance":
+			conf.Performance = flags.Performance
+		case "platform":
+			conf.Target.PlatformVersion = flags.Target.PlatformVersion
+		case "compatibility":
+			conf.Target.CompatibilityMode = flags.Target.CompatibilityMode
+		case "safe-mode":
+			conf.Target.SafeMode = flags.Target.SafeMode
+		case "clients":
+			conf.Target.Clients = nil
+			for _, client := range strings.Split(opt.clients, ",") {
+				if client = strings.TrimSpace(client); client != "" {
+					conf.Target.Clients = append(conf.Target.Clients, obfuscator.ClientType(client))
+				}
+			}
+		}
+	})
+
+	if opt.passes != "" {
+		conf.Passes = strings.Split(opt.passes, ",")
+		for i := range conf.Passes {
+			conf.Passes[i] = strings.TrimSpace(conf.Passes[i])
+		}
+	}
+
+	return conf, true, nil
+}
+
+func presetNames() string {
+	var names []string
+	for _, p := range obfuscator.Presets() {
+		names = append(names, string(p))
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func readInput(path string) (string, error) {
+	if path == "" {
+		data, err := io.ReadAll(os.Stdin)
+		return string(data), errors.Wrap(err, "read stdin error")
+	}
+
+	data, err := os.ReadFile(path)
+	return string(data), errors.Wrap(err, "read file error")
+}
+
+// readNames читает имена из файла по одному в строке, пустые строки и комментарии (#, //) пропускаются
+func readNames(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read file error")
+	}
+
+	var names []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "//") {
+			names = append(names, line)
+		}
+	}
+
+	return names, nil
+}
+
+func writeOutput(path, data string) error {
+	if path == "" {
+		_, err := fmt.Fprint(os.Stdout, data)
+		return err
+	}
+
+	return errors.Wrap(os.WriteFile(path, []byte(data), 0o644), "write file error")
+}
@@ -0,0 +1,512 @@
+package interpreter
+
+import (
+	"encoding/base64"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+type builtin func(i *Interpreter, fr *frame, args []interface{}) (interface{}, error)
+
+// builtins встроенные функции, имена в нижнем регистре
+var builtins map[string]builtin
+
+func init() {
+	builtins = map[string]builtin{}
+	for names, f := range map[string]builtin{
+		"сообщить,message":                builtinMessage,
+		"выполнить,execute":               builtinExecute,
+		"вычислить,eval":                  builtinEval,
+		"описаниеошибки,errordescription": builtinErrorDescription,
+		"информацияобошибке,errorinfo":    builtinErrorInfo,
+		"строка,string":                   builtinString,
+		"xmlстрока,xmlstring":             builtinXMLString,
+		"число,number":                    builtinNumber,
+		"булево,boolean":                  builtinBoolean,
+		"стрдлина,strlen":                 builtinStrLen,
+		"лев,left":                        builtinLeft,
+		"прав,right":                      builtinRight,
+		"сред,mid":                        builtinMid,
+		"врег,upper":                      stringFunc(strings.ToUpper),
+		"нрег,lower":                      stringFunc(strings.ToLower),
+		"сокрлп,trimall":                  stringFunc(strings.TrimSpace),
+		"стрнайти,strfind":                builtinStrFind,
+		"стрзаменить,strreplace":          builtinStrReplace,
+		"кодсимвола,charcode":             builtinCharCode,
+		"символ,char":                     builtinChar,
+		"цел,int":                         numberFunc(math.Trunc),
+		"окр,round":                       builtinRound,
+		"макс,max":                        builtinMax,
+		"мин,min":                         builtinMin,
+		"значениезаполнено,valueisfilled": builtinFilled,
+		"base64значение,base64value":      builtinBase64Value,
+		"base64строка,base64string":       builtinBase64String,
+		"получитьстрокуиздвоичныхданных,getstringfrombinarydata": builtinStringFromBinary,
+		"получитьдвоичныеданныеизстроки,getbinarydatafromstring": builtinBinaryFromString,
+		"побитовоеи,bitwiseand":                  bitwise(func(a, b uint32) uint32 { return a & b }),
+		"побитовоеили,bitwiseor":                 bitwise(func(a, b uint32) uint32 { return a | b }),
+		"побитовоеисключительноеили,bitwisexor":  bitwise(func(a, b uint32) uint32 { return a ^ b }),
+		"побитовоеине,bitwiseandnot":             bitwise(func(a, b uint32) uint32 { return a &^ b }),
+		"побитовыйсдвигвлево,bitwiseshiftleft":   bitwise(func(a, b uint32) uint32 { return a << b }),
+		"побитовыйсдвигвправо,bitwiseshiftright": bitwise(func(a, b uint32) uint32 { return a >> b }),
+		"побитовоене,bitwisenot":                 builtinBitwiseNot,
+	} {
+		for _, name := range strings.Split(names, ",") {
+			builtins[name] = f
+		}
+	}
+}
+
+func arg(args []interface{}, k int) interface{} {
+	if k < len(args) {
+		return args[k]
+	}
+
+	return nil
+}
+
+func builtinMessage(i *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	i.Messages = append(i.Messages, toString(arg(args, 0)))
+	return nil, nil
+}
+
+// builtinExecute Выполнить() выполняет код в контексте вызывающего метода
+func builtinExecute(i *Interpreter, fr *frame, args []interface{}) (interface{}, error) {
+	body, err := parse(toString(arg(args, 0)))
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := i.execBlock(fr, body)
+	if err == nil && s.flow != flowNext {
+		err = raise("Недопустимый оператор в Выполнить()")
+	}
+
+	return nil, err
+}
+
+// builtinEval Вычислить() вычисляет выражение в контексте вызывающего метода
+func builtinEval(i *Interpreter, fr *frame, args []interface{}) (interface{}, error) {
+	body, err := parse(toString(arg(args, 0)))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) != 1 {
+		return nil, raise("Вычислить(): ожидается выражение")
+	}
+
+	return i.eval(fr, body[0])
+}
+
+func (i *Interpreter) currentError() string {
+	if len(i.errors) == 0 {
+		return ""
+	}
+
+	return i.errors[len(i.errors)-1].Message
+}
+
+func builtinErrorDescription(i *Interpreter, _ *frame, _ []interface{}) (interface{}, error) {
+	return i.currentError(), nil
+}
+
+func builtinErrorInfo(i *Interpreter, _ *frame, _ []interface{}) (interface{}, error) {
+	return NewStructure("Описание", i.currentError()), nil
+}
+
+func builtinString(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	return toString(arg(args, 0)), nil
+}
+
+func builtinXMLString(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	return toXMLString(arg(args, 0)), nil
+}
+
+func builtinNumber(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	return toNumber(arg(args, 0))
+}
+
+func builtinBoolean(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	return toBool(arg(args, 0))
+}
+
+func runes(v interface{}) []rune {
+	return []rune(toString(v))
+}
+
+func builtinStrLen(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	return float64(utf8.RuneCountInString(toString(arg(args, 0)))), nil
+}
+
+func clamp(n, max int) int {
+	return int(math.Max(0, math.Min(float64(n), float64(max))))
+}
+
+func builtinLeft(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	r := runes(arg(args, 0))
+	n, err := toInt(arg(args, 1))
+	return string(r[:clamp(n, len(r))]), err
+}
+
+func builtinRight(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	r := runes(arg(args, 0))
+	n, err := toInt(arg(args, 1))
+	return string(r[len(r)-clamp(n, len(r)):]), err
+}
+
+func builtinMid(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	r := runes(arg(args, 0))
+	start, err := toInt(arg(args, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	from := clamp(start-1, len(r))
+	to := len(r)
+	if len(args) > 2 {
+		n, err := toInt(args[2])
+		if err != nil {
+			return nil, err
+		}
+		to = clamp(from+n, len(r))
+	}
+
+	return string(r[from:to]), nil
+}
+
+func stringFunc(f func(string) string) builtin {
+	return func(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+		return f(toString(arg(args, 0))), nil
+	}
+}
+
+func numberFunc(f func(float64) float64) builtin {
+	return func(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+		n, err := toNumber(arg(args, 0))
+		return f(n), err
+	}
+}
+
+func builtinStrFind(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	s, sub := toString(arg(args, 0)), toString(arg(args, 1))
+	idx := strings.Index(s, sub)
+	if idx < 0 {
+		return float64(0), nil
+	}
+
+	return float64(utf8.RuneCountInString(s[:idx]) + 1), nil
+}
+
+func builtinStrReplace(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	return strings.ReplaceAll(toString(arg(args, 0)), toString(arg(args, 1)), toString(arg(args, 2))), nil
+}
+
+func builtinCharCode(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	r := runes(arg(args, 0))
+	pos := 1
+	if len(args) > 1 {
+		var err error
+		if pos, err = toInt(args[1]); err != nil {
+			return nil, err
+		}
+	}
+	if pos < 1 || pos > len(r) {
+		return float64(-1), nil
+	}
+
+	return float64(r[pos-1]), nil
+}
+
+func builtinChar(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	n, err := toInt(arg(args, 0))
+	return string(rune(n)), err
+}
+
+func builtinRound(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	n, err := toNumber(arg(args, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	digits := 0
+	if len(args) > 1 {
+		if digits, err = toInt(args[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	p := math.Pow(10, float64(digits))
+	return math.Round(n*p) / p, nil
+}
+
+func extremum(args []interface{}, better func(a, b float64) bool) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, raise("Недостаточно фактических параметров")
+	}
+
+	result, err := toNumber(args[0])
+	for _, a := range args[1:] {
+		n, err := toNumber(a)
+		if err != nil {
+			return nil, err
+		}
+		if better(n, result) {
+			result = n
+		}
+	}
+
+	return result, err
+}
+
+func builtinMax(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	return extremum(args, func(a, b float64) bool { return a > b })
+}
+
+func builtinMin(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	return extremum(args, func(a, b float64) bool { return a < b })
+}
+
+func builtinFilled(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	switch v := arg(args, 0).(type) {
+	case nil, Null:
+		return false, nil
+	case string:
+		return strings.TrimSpace(v) != "", nil
+	case float64:
+		return v != 0, nil
+	case bool:
+		return true, nil
+	case *Array:
+		return len(v.Items) > 0, nil
+	case *Structure:
+		return len(v.keys) > 0, nil
+	}
+
+	return true, nil
+}
+
+func builtinBase64Value(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	data, err := base64.StdEncoding.DecodeString(toString(arg(args, 0)))
+	if err != nil {
+		return []byte{}, nil
+	}
+
+	return data, nil
+}
+
+func builtinBase64String(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	data, ok := arg(args, 0).([]byte)
+	if !ok {
+		return nil, raise("Неверный тип параметра (Base64Строка)")
+	}
+
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func builtinStringFromBinary(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	data, ok := arg(args, 0).([]byte)
+	if !ok {
+		return nil, raise("Неверный тип параметра (ПолучитьСтрокуИзДвоичныхДанных)")
+	}
+
+	return string(data), nil
+}
+
+func builtinBinaryFromString(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	return []byte(toString(arg(args, 0))), nil
+}
+
+func toUint32(v interface{}) (uint32, error) {
+	n, err := toNumber(v)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n > math.MaxUint32 || n != math.Trunc(n) {
+		return 0, raise("Значение параметра побитовой операции должно быть целым числом от 0 до 4294967295")
+	}
+
+	return uint32(n), nil
+}
+
+func bitwise(op func(a, b uint32) uint32) builtin {
+	return func(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+		a, err := toUint32(arg(args, 0))
+		if err != nil {
+			return nil, err
+		}
+		b, err := toUint32(arg(args, 1))
+		if err != nil {
+			return nil, err
+		}
+
+		return float64(op(a, b)), nil
+	}
+}
+
+func builtinBitwiseNot(_ *Interpreter, _ *frame, args []interface{}) (interface{}, error) {
+	a, err := toUint32(arg(args, 0))
+	return float64(^a), err
+}
+
+// newObject конструктор Новый
+func newObject(constructor string, args []interface{}) (interface{}, error) {
+	switch strings.ToLower(constructor) {
+	case "массив", "array":
+		arr := &Array{}
+		if len(args) > 0 && args[0] != nil {
+			n, err := toInt(args[0])
+			if err != nil {
+				return nil, err
+			}
+			arr.Items = make([]interface{}, n)
+		}
+		return arr, nil
+	case "структура", "structure":
+		s := NewStructure()
+		if len(args) > 0 {
+			for k, key := range strings.Split(toString(args[0]), ",") {
+				if key = strings.TrimSpace(key); key != "" {
+					s.Set(key, arg(args, k+1))
+				}
+			}
+		}
+		return s, nil
+	}
+
+	return nil, raise("Тип не определен (%s)", constructor)
+}
+
+func property(object interface{}, name string) (interface{}, error) {
+	switch o := object.(type) {
+	case *Structure:
+		if v, ok := o.Get(name); ok {
+			return v, nil
+		}
+	case *KeyValue:
+		switch strings.ToLower(name) {
+		case "ключ", "key":
+			return o.Key, nil
+		case "значение", "value":
+			return o.Value, nil
+		}
+	}
+
+	return nil, raise("Поле объекта не обнаружено (%s)", name)
+}
+
+func index(arr *Array, key interface{}) (int, error) {
+	n, err := toInt(key)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n >= len(arr.Items) {
+		return 0, raise("Индекс находится за границами диапазона")
+	}
+
+	return n, nil
+}
+
+func getItem(object, key interface{}) (interface{}, error) {
+	switch o := object.(type) {
+	case *Array:
+		n, err := index(o, key)
+		if err != nil {
+			return nil, err
+		}
+		return o.Items[n], nil
+	case *Structure:
+		return property(o, toString(key))
+	}
+
+	return nil, raise("Получение элемента по индексу для значения не используется")
+}
+
+func setItem(object, key, value interface{}) error {
+	switch o := object.(type) {
+	case *Array:
+		n, err := index(o, key)
+		if err != nil {
+			return err
+		}
+		o.Items[n] = value
+		return nil
+	case *Structure:
+		if _, ok := o.Get(toString(key)); !ok {
+			return raise("Поле объекта не обнаружено (%s)", toString(key))
+		}
+		o.Set(toString(key), value)
+		return nil
+	}
+
+	return raise("Установка значения элемента по индексу для значения не используется")
+}
+
+// callObjectMethod методы Массив и Структура
+func callObjectMethod(_ *Interpreter, object interface{}, name string, args []interface{}) (interface{}, error) {
+	method := strings.ToLower(name)
+	switch o := object.(type) {
+	case *Array:
+		switch method {
+		case "добавить", "add":
+			o.Items = append(o.Items, arg(args, 0))
+			return nil, nil
+		case "количество", "count":
+			return float64(len(o.Items)), nil
+		case "вграница", "ubound":
+			return float64(len(o.Items) - 1), nil
+		case "получить", "get":
+			return getItem(o, arg(args, 0))
+		case "установить", "set":
+			return nil, setItem(o, arg(args, 0), arg(args, 1))
+		case "вставить", "insert":
+			n, err := toInt(arg(args, 0))
+			if err != nil {
+				return nil, err
+			}
+			if n < 0 || n > len(o.Items) {
+				return nil, raise("Индекс находится за границами диапазона")
+			}
+			o.Items = append(o.Items[:n], append([]interface{}{arg(args, 1)}, o.Items[n:]...)...)
+			return nil, nil
+		case "удалить", "delete":
+			n, err := index(o, arg(args, 0))
+			if err != nil {
+				return nil, err
+			}
+			o.Items = append(o.Items[:n], o.Items[n+1:]...)
+			return nil, nil
+		case "найти", "find":
+			for k, item := range o.Items {
+				if equal(item, arg(args, 0)) {
+					return float64(k), nil
+				}
+			}
+			return nil, nil
+		case "очистить", "clear":
+			o.Items = nil
+			return nil, nil
+		}
+	case *Structure:
+		switch method {
+		case "вставить", "insert":
+			o.Set(toString(arg(args, 0)), arg(args, 1))
+			return nil, nil
+		case "количество", "count":
+			return float64(len(o.keys)), nil
+		case "свойство", "property":
+			_, ok := o.Get(toString(arg(args, 0)))
+			return ok, nil
+		case "удалить", "delete":
+			o.Delete(toString(arg(args, 0)))
+			return nil, nil
+		case "очистить", "clear":
+			o.keys, o.values = nil, map[string]interface{}{}
+			return nil, nil
+		}
+	case nil:
+		return nil, raise("Значение не является значением объектного типа (%s)", name)
+	}
+
+	return nil, raise("Метод объекта не обнаружен (%s)", name)
+}
@@ -0,0 +1,808 @@
+// Package interpreter выполняет подмножество встроенного языка 1С: арифметику, строки, Если, циклы, Перейти,
+// тернарный оператор, процедуры и функции модуля, Выполнить()/Вычислить(), Массив и Структуру.
+// Нужен для проверки, что обфусцированный модуль вычисляет то же самое, что исходный
+package interpreter
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxSteps ограничение количества выполненных операторов по умолчанию
+const DefaultMaxSteps = 1000000
+
+// ErrStepLimit превышено ограничение количества выполненных операторов
+var ErrStepLimit = errors.New("step limit exceeded")
+
+// Interpreter выполняет разобранный модуль
+type Interpreter struct {
+	// MaxSteps ограничение количества выполненных операторов, 0 - DefaultMaxSteps
+	MaxSteps int
+
+	// Messages тексты, переданные в Сообщить(), в порядке вызова
+	Messages []string
+
+	methods map[string]*ast.FunctionOrProcedure
+	globals map[string]interface{}
+	main    ast.Statements
+	errors  []*Exception
+	steps   int
+}
+
+// frame локальные переменные выполняемого метода
+type frame struct {
+	vars map[string]interface{}
+}
+
+type flow int
+
+const (
+	flowNext flow = iota
+	flowBreak
+	flowContinue
+	flowReturn
+	flowGoto
+)
+
+// signal как продолжить выполнение после оператора
+type signal struct {
+	flow  flow
+	label string
+	value interface{}
+}
+
+// New разбирает модуль
+func New(code string) (*Interpreter, error) {
+	a := ast.NewAST(code)
+	if err := a.Parse(); err != nil {
+		return nil, errors.Wrap(err, "parse error")
+	}
+
+	return NewFromModule(&a.ModuleStatement), nil
+}
+
+// NewFromModule интерпретатор для уже разобранного модуля
+func NewFromModule(module *ast.ModuleStatement) *Interpreter {
+	i := &Interpreter{
+		methods: map[string]*ast.FunctionOrProcedure{},
+		globals: map[string]interface{}{},
+	}
+
+	for _, v := range module.GlobalVariables {
+		i.globals[strings.ToLower(v.Var.Name)] = nil
+	}
+	for _, stm := range module.Body {
+		if f, ok := stm.(*ast.FunctionOrProcedure); ok {
+			i.methods[strings.ToLower(f.Name)] = f
+		} else {
+			i.main = append(i.main, stm)
+		}
+	}
+
+	return i
+}
+
+// Run выполняет код основной программы модуля
+func (i *Interpreter) Run() error {
+	s, err := i.execBlock(&frame{vars: i.globals}, i.main)
+	if err != nil {
+		return err
+	}
+	if s.flow == flowGoto {
+		return errors.Errorf("label %q not found", s.label)
+	}
+
+	return nil
+}
+
+// Call вызывает метод модуля. Аргументы int и []interface{} приводятся к Число и Массив
+func (i *Interpreter) Call(name string, args ...interface{}) (interface{}, error) {
+	f, ok := i.methods[strings.ToLower(name)]
+	if !ok {
+		return nil, errors.Errorf("method %q not found", name)
+	}
+
+	values := make([]interface{}, len(args))
+	for k, arg := range args {
+		values[k] = fromGo(arg)
+	}
+
+	result, _, err := i.callMethod(f, values)
+	return result, err
+}
+
+// Global значение переменной модуля
+func (i *Interpreter) Global(name string) interface{} {
+	return i.globals[strings.ToLower(name)]
+}
+
+// callMethod выполняет метод, возвращает результат и значения параметров после выполнения
+func (i *Interpreter) callMethod(f *ast.FunctionOrProcedure, args []interface{}) (interface{}, []interface{}, error) {
+	if len(args) > len(f.Params) {
+		return nil, nil, raise("Слишком много фактических параметров (%s)", f.Name)
+	}
+
+	fr := &frame{vars: map[string]interface{}{}}
+	for name := range f.ExplicitVariables {
+		fr.vars[strings.ToLower(name)] = nil
+	}
+	for k, p := range f.Params {
+		var value interface{}
+		switch {
+		case k < len(args):
+			value = args[k]
+		case p.Default != nil:
+			var err error
+			if value, err = i.eval(fr, p.Default); err != nil {
+				return nil, nil, err
+			}
+		}
+		fr.vars[strings.ToLower(p.Name)] = value
+	}
+
+	s, err := i.execBlock(fr, f.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s.flow == flowGoto {
+		return nil, nil, errors.Errorf("label %q not found in %q", s.label, f.Name)
+	}
+
+	out := make([]interface{}, len(args))
+	for k := range args {
+		out[k] = fr.vars[strings.ToLower(f.Params[k].Name)]
+	}
+
+	return s.value, out, nil
+}
+
+// deref значение узла дерева, указатели на структуры разыменовываются
+func deref(stm ast.Statement) ast.Statement {
+	v := reflect.ValueOf(stm)
+	if v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Struct {
+		return v.Elem().Interface()
+	}
+
+	return stm
+}
+
+func (i *Interpreter) step() error {
+	i.steps++
+	limit := i.MaxSteps
+	if limit <= 0 {
+		limit = DefaultMaxSteps
+	}
+	if i.steps > limit {
+		return ErrStepLimit
+	}
+
+	return nil
+}
+
+// execBlock выполняет операторы блока. Переход Перейти к метке этого блока обрабатывается здесь,
+// к метке внешнего блока - передается наверх
+func (i *Interpreter) execBlock(fr *frame, body ast.Statements) (signal, error) {
+	for pc := 0; pc < len(body); pc++ {
+		s, err := i.exec(fr, body[pc])
+		if err != nil {
+			return s, err
+		}
+
+		switch s.flow {
+		case flowNext:
+		case flowGoto:
+			idx := labelIndex(body, s.label)
+			if idx < 0 {
+				return s, nil
+			}
+			pc = idx
+		default:
+			return s, nil
+		}
+	}
+
+	return signal{}, nil
+}
+
+func labelIndex(body ast.Statements, label string) int {
+	for k, stm := range body {
+		if l, ok := deref(stm).(ast.GoToLabelStatement); ok && strings.EqualFold(l.Name, label) {
+			return k
+		}
+	}
+
+	return -1
+}
+
+func (i *Interpreter) exec(fr *frame, stm ast.Statement) (signal, error) {
+	if err := i.step(); err != nil {
+		return signal{}, err
+	}
+
+	switch v := deref(stm).(type) {
+	case ast.ExpStatement:
+		if v.Operation != ast.OpEq {
+			_, err := i.eval(fr, v)
+			return signal{}, err
+		}
+
+		value, err := i.eval(fr, v.Right)
+		if err != nil {
+			return signal{}, err
+		}
+		return signal{}, i.assign(fr, v.Left, value)
+	case ast.AssignmentStatement:
+		var value interface{}
+		if len(v.Expr.Statements) > 0 {
+			var err error
+			if value, err = i.eval(fr, v.Expr.Statements[0]); err != nil {
+				return signal{}, err
+			}
+		}
+		return signal{}, i.assign(fr, v.Var, value)
+	case ast.MethodStatement, ast.CallChainStatement, ast.NewObjectStatement:
+		_, err := i.evalCall(fr, stm, false)
+		return signal{}, err
+	case ast.IfStatement:
+		return i.execIf(fr, v)
+	case ast.LoopStatement:
+		return i.execLoop(fr, v)
+	case ast.TryStatement:
+		return i.execTry(fr, v)
+	case ast.ReturnStatement:
+		if v.Param == nil {
+			return signal{flow: flowReturn}, nil
+		}
+		value, err := i.eval(fr, v.Param)
+		return signal{flow: flowReturn, value: value}, err
+	case ast.ThrowStatement:
+		if v.Param == nil {
+			if len(i.errors) == 0 {
+				return signal{}, raise("Оператор ВызватьИсключение без параметров может использоваться только в блоке Исключение")
+			}
+			return signal{}, i.errors[len(i.errors)-1]
+		}
+		value, err := i.eval(fr, v.Param)
+		if err != nil {
+			return signal{}, err
+		}
+		return signal{}, &Exception{Message: toString(value)}
+	case ast.BreakStatement:
+		return signal{flow: flowBreak}, nil
+	case ast.ContinueStatement:
+		return signal{flow: flowContinue}, nil
+	case ast.GoToStatement:
+		return signal{flow: flowGoto, label: v.Label.Name}, nil
+	case ast.GoToLabelStatement:
+		return signal{}, nil
+	case ast.FunctionOrProcedure:
+		return signal{}, nil
+	case ast.ExprStatements:
+		for _, item := range v.Statements {
+			if s, err := i.exec(fr, item); err != nil || s.flow != flowNext {
+				return s, err
+			}
+		}
+		return signal{}, nil
+	}
+
+	return signal{}, errors.Errorf("unsupported statement %T", stm)
+}
+
+func (i *Interpreter) execIf(fr *frame, v ast.IfStatement) (signal, error) {
+	ok, err := i.evalBool(fr, v.Expression)
+	if err != nil {
+		return signal{}, err
+	}
+	if ok {
+		return i.execBlock(fr, v.TrueBlock)
+	}
+
+	for _, item := range v.IfElseBlock {
+		elseIf, isIf := deref(item).(ast.IfStatement)
+		if !isIf {
+			return signal{}, errors.Errorf("unsupported ИначеЕсли %T", item)
+		}
+		if ok, err = i.evalBool(fr, elseIf.Expression); err != nil {
+			return signal{}, err
+		} else if ok {
+			return i.execBlock(fr, elseIf.TrueBlock)
+		}
+	}
+
+	return i.execBlock(fr, v.ElseBlock)
+}
+
+// loopBody выполняет тело цикла, false - цикл нужно завершить
+func (i *Interpreter) loopBody(fr *frame, body ast.Statements) (signal, bool, error) {
+	s, err := i.execBlock(fr, body)
+	if err != nil {
+		return s, false, err
+	}
+
+	switch s.flow {
+	case flowBreak:
+		return signal{}, false, nil
+	case flowReturn, flowGoto:
+		return s, false, nil
+	}
+
+	return signal{}, true, nil
+}
+
+func (i *Interpreter) execLoop(fr *frame, v ast.LoopStatement) (signal, error) {
+	switch {
+	case v.WhileExpr != nil:
+		for {
+			ok, err := i.evalBool(fr, v.WhileExpr)
+			if err != nil || !ok {
+				return signal{}, err
+			}
+			if s, next, err := i.loopBody(fr, v.Body); err != nil || !next {
+				return s, err
+			}
+		}
+	case v.In != nil:
+		collection, err := i.eval(fr, v.In)
+		if err != nil {
+			return signal{}, err
+		}
+
+		var items []interface{}
+		switch c := collection.(type) {
+		case *Array:
+			items = append(items, c.Items...)
+		case *Structure:
+			for _, k := range c.keys {
+				items = append(items, &KeyValue{Key: k, Value: c.values[strings.ToLower(k)]})
+			}
+		default:
+			return signal{}, raise("Итератор для значения не определен")
+		}
+
+		for _, item := range items {
+			if err := i.assign(fr, v.For, item); err != nil {
+				return signal{}, err
+			}
+			if s, next, err := i.loopBody(fr, v.Body); err != nil || !next {
+				return s, err
+			}
+		}
+		return signal{}, nil
+	case v.To != nil:
+		init, ok := deref(v.For).(ast.ExpStatement)
+		if !ok {
+			return signal{}, errors.Errorf("unsupported loop counter %T", v.For)
+		}
+		if _, err := i.exec(fr, v.For); err != nil {
+			return signal{}, err
+		}
+
+		to, err := i.eval(fr, v.To)
+		if err != nil {
+			return signal{}, err
+		}
+		limit, err := toNumber(to)
+		if err != nil {
+			return signal{}, err
+		}
+
+		for {
+			counter, err := i.eval(fr, init.Left)
+			if err != nil {
+				return signal{}, err
+			}
+			n, err := toNumber(counter)
+			if err != nil || n > limit {
+				return signal{}, err
+			}
+			if s, next, err := i.loopBody(fr, v.Body); err != nil || !next {
+				return s, err
+			}
+
+			if counter, err = i.eval(fr, init.Left); err != nil {
+				return signal{}, err
+			}
+			if n, err = toNumber(counter); err != nil {
+				return signal{}, err
+			}
+			if err := i.assign(fr, init.Left, n+1); err != nil {
+				return signal{}, err
+			}
+		}
+	}
+
+	return signal{}, errors.New("unsupported loop")
+}
+
+func (i *Interpreter) execTry(fr *frame, v ast.TryStatement) (signal, error) {
+	s, err := i.execBlock(fr, v.Body)
+	exception, ok := err.(*Exception)
+	if !ok {
+		return s, err
+	}
+
+	i.errors = append(i.errors, exception)
+	defer func() { i.errors = i.errors[:len(i.errors)-1] }()
+
+	return i.execBlock(fr, v.Catch)
+}
+
+// lookup значение переменной: локальные, затем переменные модуля
+func (i *Interpreter) lookup(fr *frame, name string) (interface{}, error) {
+	lower := strings.ToLower(name)
+	if v, ok := fr.vars[lower]; ok {
+		return v, nil
+	}
+	if v, ok := i.globals[lower]; ok {
+		return v, nil
+	}
+
+	switch lower {
+	case "истина", "true":
+		return true, nil
+	case "ложь", "false":
+		return false, nil
+	case "неопределено", "undefined":
+		return nil, nil
+	case "null":
+		return Null{}, nil
+	}
+
+	return nil, raise("Переменная не определена (%s)", name)
+}
+
+func (i *Interpreter) assign(fr *frame, target ast.Statement, value interface{}) error {
+	switch t := deref(target).(type) {
+	case ast.VarStatement:
+		lower := strings.ToLower(t.Name)
+		if _, ok := fr.vars[lower]; !ok {
+			if _, ok := i.globals[lower]; ok {
+				i.globals[lower] = value
+				return nil
+			}
+		}
+		fr.vars[lower] = value
+		return nil
+	case ast.ItemStatement:
+		object, err := i.eval(fr, t.Object)
+		if err != nil {
+			return err
+		}
+		key, err := i.eval(fr, t.Item)
+		if err != nil {
+			return err
+		}
+		return setItem(object, key, value)
+	case ast.CallChainStatement:
+		receiver, member := chainParts(t)
+		prop, ok := deref(member).(ast.VarStatement)
+		if !ok {
+			return errors.Errorf("unsupported assignment to %T", member)
+		}
+		object, err := i.eval(fr, receiver)
+		if err != nil {
+			return err
+		}
+		s, ok := object.(*Structure)
+		if !ok {
+			return raise("Поле объекта не обнаружено (%s)", prop.Name)
+		}
+		if _, ok := s.Get(prop.Name); !ok {
+			return raise("Поле объекта не обнаружено (%s)", prop.Name)
+		}
+		s.Set(prop.Name, value)
+		return nil
+	}
+
+	return errors.Errorf("unsupported assignment to %T", target)
+}
+
+// chainParts объект и обращение к нему в цепочке Объект.Поле или Объект.Метод()
+func chainParts(chain ast.CallChainStatement) (receiver, member ast.Statement) {
+	return chain.Call, chain.Unit
+}
+
+func (i *Interpreter) evalBool(fr *frame, stm ast.Statement) (bool, error) {
+	value, err := i.eval(fr, stm)
+	if err != nil {
+		return false, err
+	}
+
+	return toBool(value)
+}
+
+func (i *Interpreter) eval(fr *frame, stm ast.Statement) (interface{}, error) {
+	switch v := deref(stm).(type) {
+	case nil:
+		return nil, nil
+	case float64, string, bool, time.Time:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case ast.VarStatement:
+		return i.lookup(fr, v.Name)
+	case ast.ExpStatement:
+		return i.evalExp(fr, v)
+	case ast.TernaryStatement:
+		ok, err := i.evalBool(fr, v.Expression)
+		if err != nil {
+			return nil, err
+		}
+		return i.eval(fr, ast.IF(ok, v.TrueBlock, v.ElseBlock))
+	case ast.ItemStatement:
+		object, err := i.eval(fr, v.Object)
+		if err != nil {
+			return nil, err
+		}
+		key, err := i.eval(fr, v.Item)
+		if err != nil {
+			return nil, err
+		}
+		return getItem(object, key)
+	case ast.MethodStatement, ast.CallChainStatement, ast.NewObjectStatement:
+		return i.evalCall(fr, stm, true)
+	case ast.ExprStatements:
+		if len(v.Statements) == 0 {
+			return nil, nil
+		}
+		return i.eval(fr, v.Statements[len(v.Statements)-1])
+	case ast.NotStatement:
+		ok, err := i.evalBool(fr, v.Param)
+		return !ok, err
+	case ast.UndefinedStatement:
+		return nil, nil
+	}
+
+	// унарный минус разбирается как ExpStatement без левой части
+	return nil, errors.Errorf("unsupported expression %T", stm)
+}
+
+func (i *Interpreter) evalExp(fr *frame, v ast.ExpStatement) (interface{}, error) {
+	if v.Left == nil && v.Operation == ast.OpMinus {
+		value, err := i.eval(fr, v.Right)
+		if err != nil {
+			return nil, err
+		}
+		n, err := toNumber(value)
+		return -n, err
+	}
+
+	left, err := i.eval(fr, v.Left)
+	if err != nil {
+		return nil, err
+	}
+
+	// И/ИЛИ вычисляются по сокращенной схеме
+	switch v.Operation {
+	case ast.OpAnd, ast.OpOr:
+		l, err := toBool(left)
+		if err != nil {
+			return nil, err
+		}
+		if l == (v.Operation == ast.OpOr) {
+			return l, nil
+		}
+		return i.evalBool(fr, v.Right)
+	}
+
+	right, err := i.eval(fr, v.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v.Operation {
+	case ast.OpEq:
+		return equal(left, right), nil
+	case ast.OpNe:
+		return !equal(left, right), nil
+	case ast.OpPlus:
+		if s, ok := left.(string); ok {
+			return s + toString(right), nil
+		}
+		if d, ok := left.(time.Time); ok {
+			n, err := toNumber(right)
+			return d.Add(time.Duration(n) * time.Second), err
+		}
+	case ast.OpGt, ast.OpLt, ast.OpGe, ast.OpLe:
+		return compare(v.Operation, left, right)
+	}
+
+	l, err := toNumber(left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := toNumber(right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v.Operation {
+	case ast.OpPlus:
+		return l + r, nil
+	case ast.OpMinus:
+		return l - r, nil
+	case ast.OpMul:
+		return l * r, nil
+	case ast.OpDiv:
+		if r == 0 {
+			return nil, raise("Деление на 0")
+		}
+		return l / r, nil
+	case ast.OpMod:
+		if r == 0 {
+			return nil, raise("Деление на 0")
+		}
+		return l - r*float64(int64(l/r)), nil
+	}
+
+	return nil, errors.Errorf("unsupported operation %v", v.Operation)
+}
+
+func compare(op ast.OperationType, left, right interface{}) (bool, error) {
+	var cmp int
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return false, raise("Операция сравнения не применима к значениям разных типов")
+		}
+		cmp = ast.IF(l < r, -1, ast.IF(l > r, 1, 0))
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return false, raise("Операция сравнения не применима к значениям разных типов")
+		}
+		cmp = strings.Compare(l, r)
+	case time.Time:
+		r, ok := right.(time.Time)
+		if !ok {
+			return false, raise("Операция сравнения не применима к значениям разных типов")
+		}
+		cmp = ast.IF(l.Before(r), -1, ast.IF(l.After(r), 1, 0))
+	case bool:
+		r, ok := right.(bool)
+		if !ok {
+			return false, raise("Операция сравнения не применима к значениям разных типов")
+		}
+		cmp = ast.IF(l == r, 0, ast.IF(r, -1, 1))
+	default:
+		return false, raise("Операция сравнения не применима к значениям этого типа")
+	}
+
+	switch op {
+	case ast.OpGt:
+		return cmp > 0, nil
+	case ast.OpLt:
+		return cmp < 0, nil
+	case ast.OpGe:
+		return cmp >= 0, nil
+	default:
+		return cmp <= 0, nil
+	}
+}
+
+// evalCall вызов метода модуля, встроенной функции, метода объекта или конструктора.
+// needValue - результат используется в выражении, процедура в этом случае - ошибка
+func (i *Interpreter) evalCall(fr *frame, stm ast.Statement, needValue bool) (interface{}, error) {
+	switch v := deref(stm).(type) {
+	case ast.NewObjectStatement:
+		args, err := i.evalArgs(fr, v.Param.Statements)
+		if err != nil {
+			return nil, err
+		}
+		return newObject(v.Constructor, args)
+	case ast.CallChainStatement:
+		receiver, member := chainParts(v)
+		object, err := i.eval(fr, receiver)
+		if err != nil {
+			return nil, err
+		}
+
+		switch m := deref(member).(type) {
+		case ast.VarStatement:
+			return property(object, m.Name)
+		case ast.MethodStatement:
+			args, err := i.evalArgs(fr, m.Param.Statements)
+			if err != nil {
+				return nil, err
+			}
+			return callObjectMethod(i, object, m.Name, args)
+		case ast.ItemStatement:
+			name, ok := deref(m.Object).(ast.VarStatement)
+			if !ok {
+				return nil, errors.Errorf("unsupported chain member %T", m.Object)
+			}
+			value, err := property(object, name.Name)
+			if err != nil {
+				return nil, err
+			}
+			key, err := i.eval(fr, m.Item)
+			if err != nil {
+				return nil, err
+			}
+			return getItem(value, key)
+		}
+		return nil, errors.Errorf("unsupported chain member %T", member)
+	case ast.MethodStatement:
+		if f, ok := i.methods[strings.ToLower(v.Name)]; ok {
+			return i.callModuleMethod(fr, f, v.Param.Statements, needValue)
+		}
+
+		if b, ok := builtins[strings.ToLower(v.Name)]; ok {
+			args, err := i.evalArgs(fr, v.Param.Statements)
+			if err != nil {
+				return nil, err
+			}
+			return b(i, fr, args)
+		}
+
+		return nil, raise("Процедура или функция с указанным именем не определена (%s)", v.Name)
+	}
+
+	return nil, errors.Errorf("unsupported call %T", stm)
+}
+
+// callModuleMethod вызывает метод модуля. Параметры без Знач передаются по ссылке:
+// если аргумент - переменная, после вызова в нее записывается значение параметра
+func (i *Interpreter) callModuleMethod(fr *frame, f *ast.FunctionOrProcedure, params ast.Statements, needValue bool) (interface{}, error) {
+	if needValue && f.Type != ast.PFTypeFunction {
+		return nil, raise("Использование процедуры как функции (%s)", f.Name)
+	}
+
+	args, err := i.evalArgs(fr, params)
+	if err != nil {
+		return nil, err
+	}
+
+	result, out, err := i.callMethod(f, args)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, param := range params {
+		if k >= len(f.Params) || f.Params[k].IsValue {
+			continue
+		}
+		if _, ok := deref(param).(ast.VarStatement); ok {
+			if err := i.assign(fr, param, out[k]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (i *Interpreter) evalArgs(fr *frame, params ast.Statements) ([]interface{}, error) {
+	args := make([]interface{}, len(params))
+	for k, p := range params {
+		value, err := i.eval(fr, p)
+		if err != nil {
+			return nil, err
+		}
+		args[k] = value
+	}
+
+	return args, nil
+}
+
+// parse разбирает код для Выполнить() и Вычислить()
+func parse(code string) (ast.Statements, error) {
+	a := ast.NewAST(code)
+	if err := a.Parse(); err != nil {
+		return nil, raise("Синтаксическая ошибка: %v", err)
+	}
+
+	return a.ModuleStatement.Body, nil
+}
@@ -0,0 +1,201 @@
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+func run(t *testing.T, code, method string, args ...interface{}) (interface{}, []string) {
+	t.Helper()
+
+	i, err := New(code)
+	if !assert.NoError(t, err) {
+		return nil, nil
+	}
+
+	result, err := i.Call(method, args...)
+	assert.NoError(t, err)
+	return result, i.Messages
+}
+
+func TestExpressions(t *testing.T) {
+	code := `Функция Тест(а, б)
+	Возврат Строка(?(а > б, (а + б) * 2 - 1, а / б)) + "/" + Строка(а % б);
+КонецФункции`
+
+	result, _ := run(t, code, "Тест", 7, 2)
+	assert.Equal(t, "17/1", result)
+
+	result, _ = run(t, code, "Тест", 1, 4)
+	assert.Equal(t, "0,25/1", result)
+}
+
+func TestConditionsAndLoops(t *testing.T) {
+	code := `Функция Тест(Коллекция)
+	Сумма = 0;
+	Для Сч = 1 По 10 Цикл
+		Если Сч = 3 Тогда
+			Продолжить;
+		ИначеЕсли Сч > 5 Тогда
+			Прервать;
+		Иначе
+			Сумма = Сумма + Сч;
+		КонецЕсли;
+	КонецЦикла;
+
+	Пока Сумма < 100 Цикл
+		Сумма = Сумма * 2;
+	КонецЦикла;
+
+	Для Каждого Элемент Из Коллекция Цикл
+		Сообщить(Элемент);
+	КонецЦикла;
+
+	Возврат Сумма;
+КонецФункции`
+
+	result, messages := run(t, code, "Тест", []interface{}{"а", 1})
+	assert.Equal(t, float64(192), result)
+	assert.Equal(t, []string{"а", "1"}, messages)
+}
+
+func TestGoto(t *testing.T) {
+	code := `Функция Тест()
+	Сч = 0;
+	~начало:
+	Если Сч >= 3 Тогда
+		Перейти ~конец;
+	КонецЕсли;
+	Сч = Сч + 1;
+	Перейти ~начало;
+	~конец:
+	Возврат Сч;
+КонецФункции`
+
+	result, _ := run(t, code, "Тест")
+	assert.Equal(t, float64(3), result)
+}
+
+func TestByRefParams(t *testing.T) {
+	code := `Процедура Увеличить(Значение, Знач Копия)
+	Значение = Значение + 1;
+	Копия = Копия + 1;
+КонецПроцедуры
+
+Функция Тест()
+	а = 1;
+	б = 1;
+	Увеличить(а, б);
+	Возврат Строка(а) + Строка(б);
+КонецФункции`
+
+	result, _ := run(t, code, "Тест")
+	assert.Equal(t, "21", result)
+}
+
+func TestTryAndEval(t *testing.T) {
+	code := `Функция Тест()
+	Попытка
+		ВызватьИсключение "ошибка";
+	Исключение
+		Сообщить(ОписаниеОшибки());
+	КонецПопытки;
+
+	Попытка
+		а = 1 / 0;
+	Исключение
+		Сообщить("деление");
+	КонецПопытки;
+
+	Выполнить("Сообщить(""выполнить"")");
+	Возврат Вычислить("2 + 2");
+КонецФункции`
+
+	result, messages := run(t, code, "Тест")
+	assert.Equal(t, float64(4), result)
+	assert.Equal(t, []string{"ошибка", "деление", "выполнить"}, messages)
+}
+
+func TestCollections(t *testing.T) {
+	code := `Функция Тест()
+	Массив = Новый Массив;
+	Массив.Добавить(1);
+	Массив.Добавить(2);
+	Массив.Вставить(0, 0);
+
+	Структура = Новый Структура("а, б", 1, 2);
+	Структура.Вставить("в", Массив.Количество());
+	Структура.а = Массив[2];
+
+	Для Каждого КлючЗначение Из Структура Цикл
+		Сообщить(КлючЗначение.Ключ + "=" + КлючЗначение.Значение);
+	КонецЦикла;
+
+	Возврат Массив;
+КонецФункции`
+
+	result, messages := run(t, code, "Тест")
+	assert.True(t, Equal(&Array{Items: []interface{}{float64(0), float64(1), float64(2)}}, result))
+	assert.Equal(t, []string{"а=2", "б=2", "в=3"}, messages)
+}
+
+func TestStringDecode(t *testing.T) {
+	// так обфускатор раскодирует строки: Base64 и побитовое исключающее или с ключом
+	code := `Функция Декодер(Знач Строка, Ключ)
+	Строка = ПолучитьСтрокуИзДвоичныхДанных(Base64Значение(Строка));
+	Результат = "";
+	Для Сч = 1 По СтрДлина(Строка) Цикл
+		Код = КодСимвола(Сред(Строка, Сч, 1));
+		Результат = Результат + Символ(ПобитовоеИсключительноеИли(Код, Ключ));
+	КонецЦикла;
+
+	Возврат Результат;
+КонецФункции`
+
+	result, _ := run(t, code, "Декодер", "0LrRpdCd0JfQkNGn", 37)
+	assert.Equal(t, "Привет", result)
+}
+
+func TestStepLimit(t *testing.T) {
+	i, err := New(`Процедура Тест()
+	Пока Истина Цикл
+	КонецЦикла;
+КонецПроцедуры`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	i.MaxSteps = 100
+	_, err = i.Call("Тест")
+	assert.ErrorIs(t, err, ErrStepLimit)
+}
+
+func TestUnary(t *testing.T) {
+	i := NewFromModule(&ast.ModuleStatement{})
+	fr := &frame{vars: map[string]interface{}{}}
+
+	value, err := i.eval(fr, ast.NotStatement{Param: true})
+	assert.NoError(t, err)
+	assert.Equal(t, false, value)
+
+	value, err = i.eval(fr, &ast.ExpStatement{Operation: ast.OpMinus, Right: float64(2)})
+	assert.NoError(t, err)
+	assert.Equal(t, float64(-2), value)
+
+	value, err = i.eval(fr, ast.UndefinedStatement{})
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	_, err = i.eval(fr, ast.GoToLabelStatement{Name: "Метка"})
+	assert.ErrorContains(t, err, "unsupported expression")
+}
+
+func TestEqual(t *testing.T) {
+	assert.True(t, Equal(NewStructure("а", float64(1)), NewStructure("А", float64(1))))
+	assert.False(t, Equal(NewStructure("а", float64(1)), NewStructure("а", "1")))
+	assert.False(t, Equal(&Array{Items: []interface{}{float64(1)}}, &Array{}))
+	assert.True(t, Equal(nil, nil))
+	assert.False(t, Equal(nil, Null{}))
+}
@@ -0,0 +1,283 @@
+package interpreter
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Array значение типа Массив
+type Array struct {
+	Items []interface{}
+}
+
+// Structure значение типа Структура, ключи хранятся в порядке добавления
+type Structure struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// KeyValue элемент структуры при обходе Для Каждого
+type KeyValue struct {
+	Key   string
+	Value interface{}
+}
+
+// Null значение NULL
+type Null struct{}
+
+// Exception исключение встроенного языка, его можно перехватить в Попытка
+type Exception struct {
+	Message string
+}
+
+func (e *Exception) Error() string {
+	return e.Message
+}
+
+func raise(format string, args ...interface{}) error {
+	return &Exception{Message: errors.Errorf(format, args...).Error()}
+}
+
+// NewStructure создает структуру с указанными ключами и значениями
+func NewStructure(pairs ...interface{}) *Structure {
+	s := &Structure{values: map[string]interface{}{}}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		s.Set(pairs[i].(string), pairs[i+1])
+	}
+
+	return s
+}
+
+// Set добавляет или изменяет значение ключа
+func (s *Structure) Set(key string, value interface{}) {
+	lower := strings.ToLower(key)
+	if _, ok := s.values[lower]; !ok {
+		s.keys = append(s.keys, key)
+	}
+	s.values[lower] = value
+}
+
+// Get значение ключа
+func (s *Structure) Get(key string) (interface{}, bool) {
+	v, ok := s.values[strings.ToLower(key)]
+	return v, ok
+}
+
+// Delete удаляет ключ
+func (s *Structure) Delete(key string) {
+	lower := strings.ToLower(key)
+	delete(s.values, lower)
+	for i, k := range s.keys {
+		if strings.ToLower(k) == lower {
+			s.keys = append(s.keys[:i:i], s.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Keys ключи в порядке добавления
+func (s *Structure) Keys() []string {
+	return append([]string{}, s.keys...)
+}
+
+// Equal равны ли значения. Массивы и структуры сравниваются по содержимому, это нужно для сравнения результатов
+// исходного и обфусцированного кода, в самом встроенном языке они сравниваются по ссылке
+func Equal(a, b interface{}) bool {
+	switch x := a.(type) {
+	case *Array:
+		y, ok := b.(*Array)
+		if !ok || len(x.Items) != len(y.Items) {
+			return false
+		}
+		for i := range x.Items {
+			if !Equal(x.Items[i], y.Items[i]) {
+				return false
+			}
+		}
+		return true
+	case *Structure:
+		y, ok := b.(*Structure)
+		if !ok || len(x.keys) != len(y.keys) {
+			return false
+		}
+		for _, k := range x.keys {
+			v, ok := y.Get(k)
+			if !ok || !Equal(x.values[strings.ToLower(k)], v) {
+				return false
+			}
+		}
+		return true
+	case *KeyValue:
+		y, ok := b.(*KeyValue)
+		return ok && x.Key == y.Key && Equal(x.Value, y.Value)
+	case float64:
+		// переполнение в обоих вариантах кода дает одинаковый NaN
+		if y, ok := b.(float64); ok && math.IsNaN(x) && math.IsNaN(y) {
+			return true
+		}
+	}
+
+	return equal(a, b)
+}
+
+// equal сравнение значений оператором =
+func equal(a, b interface{}) bool {
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		return ok && x == y
+	case string:
+		y, ok := b.(string)
+		return ok && x == y
+	case bool:
+		y, ok := b.(bool)
+		return ok && x == y
+	case time.Time:
+		y, ok := b.(time.Time)
+		return ok && x.Equal(y)
+	case nil:
+		return b == nil
+	case Null:
+		_, ok := b.(Null)
+		return ok
+	case []byte:
+		y, ok := b.([]byte)
+		return ok && string(x) == string(y)
+	}
+
+	return a == b
+}
+
+// fromGo приводит значения Go к значениям интерпретатора
+func fromGo(v interface{}) interface{} {
+	switch x := v.(type) {
+	case int:
+		return float64(x)
+	case int32:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case float32:
+		return float64(x)
+	case []interface{}:
+		arr := &Array{}
+		for _, item := range x {
+			arr.Items = append(arr.Items, fromGo(item))
+		}
+		return arr
+	}
+
+	return v
+}
+
+// toString представление значения, как в Строка()
+func toString(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case float64:
+		return formatNumber(x, "\u00a0", ",")
+	case bool:
+		return map[bool]string{true: "Да", false: "Нет"}[x]
+	case nil, Null:
+		return ""
+	case time.Time:
+		return x.Format("02.01.2006 15:04:05")
+	case *Array:
+		return "Массив"
+	case *Structure:
+		return "Структура"
+	case *KeyValue:
+		return "КлючИЗначение"
+	case []byte:
+		return "ДвоичныеДанные"
+	}
+
+	return ""
+}
+
+// toXMLString представление значения, как в XMLСтрока()
+func toXMLString(v interface{}) string {
+	switch x := v.(type) {
+	case float64:
+		return formatNumber(x, "", ".")
+	case bool:
+		return strconv.FormatBool(x)
+	case time.Time:
+		return x.Format("2006-01-02T15:04:05")
+	}
+
+	return toString(v)
+}
+
+func formatNumber(x float64, group, point string) string {
+	s := strconv.FormatFloat(math.Abs(x), 'f', -1, 64)
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i+1:]
+	}
+
+	var b strings.Builder
+	if x < 0 {
+		b.WriteString("-")
+	}
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(group)
+		}
+		b.WriteRune(r)
+	}
+	if frac != "" {
+		b.WriteString(point + frac)
+	}
+
+	return b.String()
+}
+
+// toNumber приведение к числу, строка должна содержать число
+func toNumber(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		s := strings.NewReplacer(" ", "", "\u00a0", "", ",", ".").Replace(strings.TrimSpace(x))
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			return n, nil
+		}
+	}
+
+	return 0, raise("Преобразование значения к типу Число не может быть выполнено")
+}
+
+// toBool приведение к булеву, как в условии Если
+func toBool(v interface{}) (bool, error) {
+	switch x := v.(type) {
+	case bool:
+		return x, nil
+	case float64:
+		return x != 0, nil
+	case string:
+		switch strings.ToLower(x) {
+		case "истина", "true", "да":
+			return true, nil
+		case "ложь", "false", "нет":
+			return false, nil
+		}
+	}
+
+	return false, raise("Преобразование значения к типу Булево не может быть выполнено")
+}
+
+func toInt(v interface{}) (int, error) {
+	n, err := toNumber(v)
+	return int(n), err
+}
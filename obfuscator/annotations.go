@@ -0,0 +1,275 @@
+package obfuscator
+
+import (
+	"bufio"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+	"github.com/pkg/errors"
+)
+
+// Аннотации в комментариях перед методом или перед #Область:
+//
+//	// obfuscate:off                   - не обфусцировать
+//	// obfuscate:on                    - отменить off, заданный для области
+//	// obfuscate:level=paranoid        - использовать настройки пресета
+//	// obfuscate:disable=eval,garbage  - не применять проходы
+//	// obfuscate:enable=callstack      - применить проходы, даже если они выключены в настройках
+//	// obfuscate:keep-name             - не переименовывать
+//	// obfuscate:hot                   - нагруженный метод, только преобразования без затрат при выполнении
+//
+// Несколько аннотаций можно указать в одной строке через пробел. В результат комментарии не попадают.
+var (
+	annotationRe = regexp.MustCompile(`(?i)^\s*//\s*obfuscate:\s*(.*)$`)
+	methodRe     = regexp.MustCompile(`(?i)^\s*(?:асинх\s+|async\s+)?(?:процедура|функция|procedure|function)\s+([\p{L}_][\p{L}\p{N}_]*)\s*\(`)
+	regionRe     = regexp.MustCompile(`(?i)^\s*#(?:область|region)(?:\s|$)`)
+	endRegionRe  = regexp.MustCompile(`(?i)^\s*#(?:конецобласти|endregion)(?:\s|$)`)
+	directiveRe  = regexp.MustCompile(`^\s*&`)
+	commentRe    = regexp.MustCompile(`^\s*//`)
+)
+
+type annotation struct {
+	off      *bool
+	keepName bool
+	hot      bool
+	level    Preset
+	enable   map[string]struct{}
+	disable  map[string]struct{}
+}
+
+// methodSettings итоговые настройки метода с учетом аннотаций
+type methodSettings struct {
+	off       bool
+	keepName  bool
+	hot       bool
+	passes    map[string]struct{}
+	intensity Intensity
+}
+
+// parseAnnotations собирает аннотации методов, ключ - имя метода в нижнем регистре
+func parseAnnotations(code string) (map[string]*annotation, error) {
+	result := map[string]*annotation{}
+
+	var pending *annotation
+	var regions []*annotation
+
+	scanner := bufio.NewScanner(strings.NewReader(code))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(code)+1)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+
+		switch {
+		case annotationRe.MatchString(text):
+			if pending == nil {
+				pending = new(annotation)
+			}
+			if err := pending.parse(annotationRe.FindStringSubmatch(text)[1]); err != nil {
+				return nil, errors.Wrapf(err, "line %d", line)
+			}
+		case regionRe.MatchString(text):
+			regions = append(regions, pending)
+			pending = nil
+		case endRegionRe.MatchString(text):
+			if len(regions) > 0 {
+				regions = regions[:len(regions)-1]
+			}
+			pending = nil
+		case methodRe.MatchString(text):
+			name := strings.ToLower(methodRe.FindStringSubmatch(text)[1])
+
+			var a *annotation
+			for _, r := range regions {
+				a = a.merge(r)
+			}
+			if a = a.merge(pending); a != nil {
+				result[name] = a
+			}
+			pending = nil
+		case strings.TrimSpace(text) == "", commentRe.MatchString(text), directiveRe.MatchString(text):
+			// аннотация относится к ближайшему методу или области
+		default:
+			pending = nil
+		}
+	}
+
+	return result, scanner.Err()
+}
+
+func (a *annotation) parse(text string) error {
+	for _, token := range strings.FieldsFunc(text, func(r rune) bool { return r == ' ' || r == '\t' || r == ';' }) {
+		key, value, _ := strings.Cut(strings.ToLower(token), "=")
+
+		switch key {
+		case "off":
+			a.off = ptr(true)
+		case "on":
+			a.off = ptr(false)
+		case "keep-name":
+			a.keepName = true
+		case "hot":
+			a.hot = true
+		case "level":
+			if _, err := Preset(value).Config(); err != nil {
+				return err
+			}
+			a.level = Preset(value)
+		case "enable", "disable":
+			passes := map[string]struct{}{}
+			for _, name := range strings.Split(value, ",") {
+				if _, ok := LookupTransform(name); !ok {
+					return errors.Errorf("unknown transform %q", name)
+				}
+				passes[name] = struct{}{}
+			}
+
+			if key == "enable" {
+				a.enable = mergeSet(a.enable, passes)
+			} else {
+				a.disable = mergeSet(a.disable, passes)
+			}
+		default:
+			return errors.Errorf("unknown annotation %q", token)
+		}
+	}
+
+	return nil
+}
+
+// merge аннотация метода дополняет и переопределяет аннотацию области
+func (a *annotation) merge(over *annotation) *annotation {
+	if over == nil {
+		return a
+	}
+	if a == nil {
+		a = new(annotation)
+	}
+
+	result := &annotation{
+		off:      a.off,
+		keepName: a.keepName || over.keepName,
+		hot:      a.hot || over.hot,
+		level:    a.level,
+		enable:   mergeSet(mergeSet(nil, a.enable), over.enable),
+		disable:  mergeSet(mergeSet(nil, a.disable), over.disable),
+	}
+	if over.off != nil {
+		result.off = over.off
+	}
+	if over.level != "" {
+		result.level = over.level
+	}
+
+	return result
+}
+
+func mergeSet(a, b map[string]struct{}) map[string]struct{} {
+	if a == nil {
+		a = map[string]struct{}{}
+	}
+	for k := range b {
+		a[k] = struct{}{}
+	}
+
+	return a
+}
+
+// methodSettings настройки метода, для методов без аннотаций используются общие настройки
+func (c *Obfuscator) methodSettings(f *ast.FunctionOrProcedure) *methodSettings {
+	key := strings.ToLower(f.Name)
+	if s, ok := c.methods[key]; ok {
+		return s
+	}
+
+	s := &methodSettings{
+		passes:    mergeSet(nil, nil),
+		intensity: c.conf.Intensity.withDefaults(),
+	}
+	for _, name := range c.basePasses {
+		s.passes[name] = struct{}{}
+	}
+
+	if a, ok := c.annotations[key]; ok {
+		s.off = a.off != nil && *a.off
+		s.keepName = a.keepName
+		s.hot = a.hot
+
+		if a.level != "" {
+			conf, _ := a.level.Config()
+			s.intensity = conf.Intensity.withDefaults()
+			s.passes = mergeSet(nil, nil)
+			for _, name := range conf.defaultPasses() {
+				s.passes[name] = struct{}{}
+			}
+		}
+
+		s.passes = mergeSet(s.passes, a.enable)
+		for name := range a.disable {
+			delete(s.passes, name)
+		}
+	}
+
+	s.hot = s.hot || c.isHotMethod(f)
+	if s.hot {
+		for pass := range passCost {
+			delete(s.passes, pass)
+		}
+	}
+
+	// уменьшение интенсивности, чтобы уложиться в Config.Budget
+	s.intensity = s.intensity.scaled(c.scale)
+
+	c.methods[key] = s
+	return s
+}
+
+// allowed можно ли применять проход к методу
+func (c *Obfuscator) allowed(f *ast.FunctionOrProcedure, pass string) bool {
+	if f == nil {
+		return true
+	}
+
+	s := c.methodSettings(f)
+	if s.off {
+		return false
+	}
+
+	_, ok := s.passes[pass]
+	return ok
+}
+
+// annotatedPasses проходы, которые включены только аннотациями
+func (c *Obfuscator) annotatedPasses() []string {
+	used := map[string]struct{}{}
+	for _, a := range c.annotations {
+		if a.level != "" {
+			conf, _ := a.level.Config()
+			used = mergeSet(used, toSet(conf.defaultPasses()))
+		}
+		used = mergeSet(used, a.enable)
+	}
+
+	var result, custom []string
+	for _, name := range builtinPasses {
+		if _, ok := used[name]; ok {
+			result = append(result, name)
+			delete(used, name)
+		}
+	}
+	for name := range used {
+		custom = append(custom, name)
+	}
+
+	sort.Strings(custom)
+	return append(result, custom...)
+}
+
+func toSet(items []string) map[string]struct{} {
+	result := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		result[item] = struct{}{}
+	}
+
+	return result
+}
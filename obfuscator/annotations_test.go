@@ -0,0 +1,85 @@
+package obfuscator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAnnotations(t *testing.T) {
+	code := `
+// obfuscate:disable=garbage
+#Область Служебные
+
+// obfuscate:off
+&НаСервере
+Процедура Горячая()
+КонецПроцедуры
+
+// obfuscate:level=paranoid keep-name
+Функция Секретная() Экспорт
+КонецФункции
+
+Процедура Обычная()
+КонецПроцедуры
+
+#КонецОбласти
+
+Процедура ВнеОбласти()
+КонецПроцедуры
+`
+
+	annotations, err := parseAnnotations(code)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if a := annotations["горячая"]; assert.NotNil(t, a) {
+		assert.True(t, *a.off)
+		assert.Contains(t, a.disable, PassGarbage)
+	}
+	if a := annotations["секретная"]; assert.NotNil(t, a) {
+		assert.Nil(t, a.off)
+		assert.True(t, a.keepName)
+		assert.Equal(t, PresetParanoid, a.level)
+	}
+	if a := annotations["обычная"]; assert.NotNil(t, a) {
+		assert.Contains(t, a.disable, PassGarbage)
+	}
+	assert.NotContains(t, annotations, "внеобласти")
+
+	_, err = parseAnnotations("// obfuscate:unknown\nПроцедура А()\nКонецПроцедуры")
+	assert.Error(t, err)
+
+	_, err = parseAnnotations("// obfuscate:disable=unknown\nПроцедура А()\nКонецПроцедуры")
+	assert.Error(t, err)
+}
+
+func TestObfuscateAnnotations(t *testing.T) {
+	code := `&НаСервереБезКонтекста
+// obfuscate:off
+Функция Горячая()
+	Возврат "горячая строка";
+КонецФункции
+
+&НаСервереБезКонтекста
+// obfuscate:level=paranoid
+Функция Секретная()
+	Сообщить("секретная строка");
+КонецФункции
+
+&НаСервереБезКонтекста
+Функция Обычная()
+	Сообщить("обычная строка");
+КонецФункции`
+
+	obf := NewObfuscatory(context.Background(), Config{RepLoopByGoto: true})
+	obCode, err := obf.Obfuscate(code)
+	if assert.NoError(t, err) {
+		assert.Contains(t, obCode, `"горячая строка"`)
+		assert.NotContains(t, obCode, "секретная строка")
+		assert.Contains(t, obCode, `"обычная строка"`)
+		assert.NotContains(t, obCode, "obfuscate:")
+	}
+}
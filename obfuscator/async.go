@@ -0,0 +1,84 @@
+package obfuscator
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+)
+
+// Асинхронные методы (Асинх Процедура/Функция) и выражения Ждать.
+// Асинх убирается из текста для разбора и восстанавливается при выводе, остальной метод обфусцируется как обычно.
+// В дереве разбора нет узла для Ждать, поэтому асинхронные методы с Ждать не разбираются:
+// они, как и методы с &ИзменениеИКонтроль, выводятся без изменений
+var (
+	asyncRe = regexp.MustCompile(`(?i)^\s*(?:асинх|async)\s+`)
+	awaitRe = regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}_.])(?:ждать|await)(?:[^\p{L}\p{N}_]|$)`)
+)
+
+// hasAwait есть ли Ждать в методе, который объявлен в строке from. Строки и комментарии не исключаются:
+// лишнее совпадение только оставляет метод без изменений
+func hasAwait(lines []string, from int) bool {
+	for i := from; i < len(lines); i++ {
+		if awaitRe.MatchString(lines[i]) {
+			return true
+		}
+		if endMethodRe.MatchString(lines[i]) {
+			return false
+		}
+	}
+
+	return false
+}
+
+func findValue(v reflect.Value, pred func(ast.Statement) bool) bool {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return false
+		}
+		if v.CanInterface() && pred(v.Interface()) {
+			return true
+		}
+		return findValue(v.Elem(), pred)
+	case reflect.Interface:
+		return !v.IsNil() && findValue(v.Elem(), pred)
+	case reflect.Struct:
+		if v.CanInterface() && pred(v.Interface()) {
+			return true
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() && findValue(v.Field(i), pred) {
+				return true
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if findValue(v.Index(i), pred) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isAsync объявлен ли метод как Асинх
+func (c *Obfuscator) isAsync(f *ast.FunctionOrProcedure) bool {
+	return c.async[f]
+}
+
+// printAsync выводит метод, объявленный как Асинх. Ключевое слово стоит между директивой и объявлением,
+// поэтому метод печатается без директивы, а директива и Асинх добавляются перед ним
+func (c *Obfuscator) printAsync(f *ast.FunctionOrProcedure, conf ast.PrintConf) string {
+	cp := *f
+	cp.Directive = ""
+
+	text := "Асинх " + strings.TrimLeft(c.a.PrintStatementWithConf(&cp, conf), "\n")
+	if f.Directive != "" {
+		text = f.Directive + "\n" + text
+	}
+
+	return text
+}
@@ -0,0 +1,97 @@
+package obfuscator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAsyncModule = `&НаКлиенте
+Асинх Процедура Спросить(Команда)
+	Ждать ПредупреждениеАсинх("Внимание");
+	Ответ = Ждать ВопросАсинх("Продолжить?", РежимДиалогаВопрос.ДаНет);
+	Если Ответ = КодВозвратаДиалога.Да Тогда
+		Обычная();
+	КонецЕсли;
+КонецПроцедуры
+
+&НаКлиенте
+Асинх Функция Загрузить(Адрес)
+	Возврат ПолучитьИзВременногоХранилища(Адрес);
+КонецФункции
+
+&НаКлиенте
+Процедура Обычная()
+	Сообщить("обычная");
+КонецПроцедуры`
+
+func TestParseAsync(t *testing.T) {
+	pre, err := parsePreprocessor(testAsyncModule)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.True(t, pre.async)
+	if assert.Len(t, pre.controlled, 1) {
+		assert.True(t, pre.controlled[0].await)
+		assert.Contains(t, pre.controlled[0].text, "Асинх Процедура Спросить(Команда)")
+	}
+	if assert.Len(t, pre.methods, 2) {
+		assert.True(t, pre.methods[0].async)
+		assert.False(t, pre.methods[0].await)
+		assert.False(t, pre.methods[1].async)
+	}
+	assert.NotContains(t, pre.source, "Асинх")
+	assert.NotContains(t, pre.source, "Ждать")
+}
+
+func TestAwaitNotParsed(t *testing.T) {
+	// Ждать Обещание не попадает в текст для разбора: узла для Ждать в дереве нет
+	code := "Асинх Процедура Тест(Обещание)\n\tЖдать Обещание;\nКонецПроцедуры"
+	pre, err := parsePreprocessor(code)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotContains(t, pre.source, "Обещание")
+	assert.Len(t, pre.methods, 0)
+
+	a := ast.NewAST(pre.source)
+	assert.NoError(t, a.Parse())
+
+	// Ждать как часть имени или свойство объекта не считается
+	assert.False(t, hasAwait([]string{"Процедура Тест()", "\tНеЖдать = Истина;", "\tОбъект.Ждать();", "КонецПроцедуры"}, 0))
+	assert.True(t, hasAwait([]string{"Процедура Тест()", "\tОтвет = Ждать Вопрос();", "КонецПроцедуры"}, 0))
+	assert.False(t, hasAwait([]string{"Процедура Тест()", "КонецПроцедуры", "Ждать Обещание;"}, 0))
+}
+
+func TestObfuscateAsync(t *testing.T) {
+	obf := NewObfuscatory(context.Background(), Config{
+		RepExpByEval:    true,
+		RepExpByTernary: true,
+		HideString:      true,
+		CallStackHell:   true,
+		RepLoopByGoto:   true,
+	})
+
+	result, err := obf.ObfuscateResult(testAsyncModule, ModuleInfo{Kind: ModuleForm})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	obCode := result.Code
+	assert.Equal(t, 1, strings.Count(obCode, "Асинх Процедура Спросить(Команда)"))
+	assert.Equal(t, 1, strings.Count(obCode, "Асинх Функция "))
+	assert.Equal(t, 2, strings.Count(obCode, "Ждать "))
+	// метод с Ждать выводится без изменений, вызываемый из него метод не переименовывается
+	assert.Contains(t, obCode, `Ждать ПредупреждениеАсинх("Внимание");`)
+	assert.Contains(t, obCode, "Процедура Обычная()")
+
+	var skipped bool
+	for _, d := range result.Diagnostics {
+		skipped = skipped || d.Code == DiagAwaitSkipped && d.Procedure == "Спросить"
+	}
+	assert.True(t, skipped)
+}
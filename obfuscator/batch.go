@@ -0,0 +1,218 @@
+package obfuscator
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// HandlersMode что делать с методами, на которые ссылаются файлы выгрузки (Form.xml, описания метаданных)
+type HandlersMode string
+
+const (
+	// HandlersPreserve не переименовывать обработчики
+	HandlersPreserve HandlersMode = "preserve"
+	// HandlersRename переименовывать обработчики и в модуле, и в файлах, которые на них ссылаются
+	HandlersRename HandlersMode = "rename"
+)
+
+// BatchOptions настройки обфускации выгрузки конфигурации в файлы
+type BatchOptions struct {
+	// ConfigFor настройки для модуля по пути относительно корня выгрузки, false - модуль не обфусцировать.
+	// Подходит FileConfig.ConfigFor
+	ConfigFor func(modulePath string) (Config, bool, error)
+
+	// FormHandlers что делать с методами формы, на которые ссылается Form.xml. По умолчанию HandlersPreserve
+	FormHandlers HandlersMode
+
+	// MetadataHandlers что делать с методами, на которые ссылаются подписки на события, регламентные задания,
+	// HTTP и web-сервисы. По умолчанию HandlersPreserve. В режиме HandlersRename экспортный метод общего модуля
+	// переименовывается, только если его имя не встречается в других модулях
+	MetadataHandlers HandlersMode
+
+	// OnResult вызывается после обфускации каждого модуля, модули обрабатываются последовательно.
+	// Отчеты из Result.Report можно собрать в сводный отчет через Summarize
+	OnResult func(result Result)
+}
+
+// ObfuscateDir обфусцирует все модули (*.bsl) выгрузки srcDir и записывает результат в dstDir,
+// остальные файлы копируются без изменений, кроме Form.xml и описаний метаданных, которые ссылаются на переименованные методы
+func ObfuscateDir(ctx context.Context, srcDir, dstDir string, opt BatchOptions) error {
+	if opt.ConfigFor == nil {
+		return errors.New("ConfigFor is not set")
+	}
+	for _, mode := range []*HandlersMode{&opt.FormHandlers, &opt.MetadataHandlers} {
+		switch *mode {
+		case "":
+			*mode = HandlersPreserve
+		case HandlersPreserve, HandlersRename:
+		default:
+			return errors.Errorf("unknown handlers mode %q", *mode)
+		}
+	}
+
+	meta, err := loadMetadataIndex(srcDir, opt.MetadataHandlers == HandlersRename)
+	if err != nil {
+		return err
+	}
+
+	written := map[string]struct{}{}
+	renames := map[string]map[string]string{}
+	err = filepath.WalkDir(srcDir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.EqualFold(filepath.Ext(filePath), ".bsl") {
+			return err
+		}
+
+		rel, err := filepath.Rel(srcDir, filePath)
+		if err != nil {
+			return err
+		}
+
+		modulePath := filepath.ToSlash(rel)
+		files, renamed, err := obfuscateFile(ctx, srcDir, modulePath, opt, meta)
+		if err != nil {
+			return errors.Wrapf(err, "module %q", rel)
+		}
+		if len(renamed) > 0 {
+			renames[strings.ToLower(modulePath)] = renamed
+		}
+
+		for name, data := range files {
+			if err := writeFile(filepath.Join(dstDir, filepath.FromSlash(name)), data); err != nil {
+				return err
+			}
+			written[name] = struct{}{}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if opt.MetadataHandlers == HandlersRename {
+		for descriptor := range meta.descriptors(renames) {
+			data, err := os.ReadFile(filepath.Join(srcDir, filepath.FromSlash(descriptor)))
+			if err != nil {
+				return err
+			}
+
+			if err := writeFile(filepath.Join(dstDir, filepath.FromSlash(descriptor)), renameMetadataHandlers(descriptor, data, renames)); err != nil {
+				return err
+			}
+			written[descriptor] = struct{}{}
+		}
+	}
+
+	// все, что не было изменено, копируется как есть
+	return filepath.WalkDir(srcDir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(srcDir, filePath)
+		if err != nil {
+			return err
+		}
+		if _, ok := written[filepath.ToSlash(rel)]; ok {
+			return nil
+		}
+
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		return writeFile(filepath.Join(dstDir, rel), data)
+	})
+}
+
+// obfuscateFile обфусцирует модуль, возвращает измененные файлы (путь относительно корня выгрузки -> содержимое)
+// и переименованные методы
+func obfuscateFile(ctx context.Context, root, modulePath string, opt BatchOptions, meta *metadataIndex) (map[string][]byte, map[string]string, error) {
+	conf, enabled, err := opt.ConfigFor(modulePath)
+	if err != nil || !enabled {
+		return nil, nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(modulePath)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var form []byte
+	formPath := formXMLPath(modulePath)
+	if formPath != "" {
+		if form, err = os.ReadFile(filepath.Join(root, filepath.FromSlash(formPath))); err != nil && !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+	}
+
+	if form != nil && opt.FormHandlers == HandlersPreserve {
+		names, err := formHandlers(form)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, formPath)
+		}
+
+		conf.PreservedNames = append(append([]string{}, conf.PreservedNames...), names...)
+	}
+
+	for _, method := range meta.methods(modulePath) {
+		if opt.MetadataHandlers == HandlersRename && !meta.calledOutside(modulePath, method) {
+			conf.renameExports = append(conf.renameExports, method)
+		} else {
+			conf.PreservedNames = append(append([]string{}, conf.PreservedNames...), method)
+		}
+	}
+
+	info := ModuleInfo{Path: modulePath}
+	if ModuleKindByPath(modulePath) == ModuleCommon {
+		descriptor, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(commonModuleXMLPath(modulePath))))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+		info.CompilationContext = commonModuleContexts(descriptor)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	bom := bytes.HasPrefix(data, utf8BOM)
+	obf := NewObfuscatory(ctx, conf)
+	res, err := obf.ObfuscateResult(string(bytes.TrimPrefix(data, utf8BOM)), info)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opt.OnResult != nil {
+		opt.OnResult(res)
+	}
+
+	obCode := res.Code
+
+	result := map[string][]byte{modulePath: []byte(obCode)}
+	if bom {
+		result[modulePath] = append(append([]byte{}, utf8BOM...), obCode...)
+	}
+
+	renames := obf.RenamedMethods()
+	if form != nil && opt.FormHandlers == HandlersRename && len(renames) > 0 {
+		result[formPath] = renameFormHandlers(form, renames)
+	}
+
+	return result, renames, nil
+}
+
+func writeFile(filePath string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filePath, data, 0o644)
+}
@@ -0,0 +1,175 @@
+package obfuscator
+
+import (
+	"fmt"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+)
+
+// maxBudgetAttempts сколько раз интенсивность уменьшается вдвое, чтобы уложиться в Budget
+const maxBudgetAttempts = 4
+
+// Budget ограничения размера результата, нулевые значения - без ограничений.
+// Если результат не укладывается в ограничения, модуль обфусцируется заново с меньшей интенсивностью
+type Budget struct {
+	// MaxSize максимальный размер модуля после обфускации в байтах
+	MaxSize int `yaml:"maxSize,omitempty" json:"maxSize,omitempty"`
+
+	// MaxGrowth во сколько раз может вырасти модуль
+	MaxGrowth float64 `yaml:"maxGrowth,omitempty" json:"maxGrowth,omitempty"`
+
+	// MaxProcedureGrowth во сколько раз может вырасти метод вместе с фейковыми функциями, созданными для него
+	MaxProcedureGrowth float64 `yaml:"maxProcedureGrowth,omitempty" json:"maxProcedureGrowth,omitempty"`
+
+	// MaxGeneratedFunctions максимальное количество служебных функций в модуле
+	MaxGeneratedFunctions int `yaml:"maxGeneratedFunctions,omitempty" json:"maxGeneratedFunctions,omitempty"`
+}
+
+func (b Budget) empty() bool {
+	return b == Budget{}
+}
+
+// merge переопределяет ограничения заданными (ненулевыми) ограничениями over
+func (b Budget) merge(over Budget) Budget {
+	if over.MaxSize > 0 {
+		b.MaxSize = over.MaxSize
+	}
+	if over.MaxGrowth > 0 {
+		b.MaxGrowth = over.MaxGrowth
+	}
+	if over.MaxProcedureGrowth > 0 {
+		b.MaxProcedureGrowth = over.MaxProcedureGrowth
+	}
+	if over.MaxGeneratedFunctions > 0 {
+		b.MaxGeneratedFunctions = over.MaxGeneratedFunctions
+	}
+
+	return b
+}
+
+// scaled интенсивность, уменьшенная в 1/k раз: вероятности, глубина и вложенность
+func (i Intensity) scaled(k float64) Intensity {
+	if k <= 0 || k >= 1 {
+		return i
+	}
+
+	for _, l := range []*Level{&i.Ternary, &i.Conditions, &i.Garbage, &i.CallStack, &i.Eval, &i.Goto, &i.Exceptions, &i.Predicates} {
+		*l = l.scaled(k)
+	}
+
+	return i
+}
+
+func (l Level) scaled(k float64) Level {
+	// вероятность уменьшается у всех уровней, у Predicates она не используется и ни на что не влияет
+	if l.Probability != nil {
+		l.Probability = ptr(*l.Probability * k)
+	}
+	l.MinDepth = max(1, int(float64(l.MinDepth)*k))
+	l.MaxDepth = max(l.MinDepth, int(float64(l.MaxDepth)*k))
+	// вложенность не уменьшается до 0, если она была: 0 выключает вложенные блоки совсем
+	if l.MaxNesting != nil && *l.MaxNesting > 0 {
+		l.MaxNesting = ptr(max(1, int(float64(*l.MaxNesting)*k)))
+	}
+
+	return l
+}
+
+// obfuscateWithinBudget обфусцирует модуль, пока результат не уложится в Config.Budget,
+// каждый раз уменьшая интенсивность вдвое
+func (c *Obfuscator) obfuscateWithinBudget(code string) (string, error) {
+	intensity := c.intensity
+	defer func() { c.intensity, c.scale = intensity, 0 }()
+
+	budget := c.conf.Budget
+	for attempt := 0; ; attempt++ {
+		obCode, err := c.obfuscate(code)
+		if err != nil || budget.empty() {
+			return obCode, err
+		}
+
+		exceeded := c.checkBudget(code, obCode)
+		switch {
+		case exceeded == "":
+			if attempt > 0 {
+				c.report(SeverityWarning, DiagBudget, nil, "intensity is reduced %d times to fit the budget", 1<<attempt)
+			}
+			return obCode, nil
+		case attempt == maxBudgetAttempts:
+			c.report(SeverityWarning, DiagBudget, nil, "output exceeds the budget at minimal intensity: %s", exceeded)
+			return obCode, nil
+		}
+
+		c.scale = 1 / float64(int(2)<<attempt)
+		c.intensity = intensity.scaled(c.scale)
+	}
+}
+
+// checkBudget описание первого превышенного ограничения, пустая строка - результат укладывается в ограничения
+func (c *Obfuscator) checkBudget(code, obCode string) string {
+	b := c.conf.Budget
+
+	switch {
+	case b.MaxSize > 0 && len(obCode) > b.MaxSize:
+		return fmt.Sprintf("size %d bytes exceeds %d", len(obCode), b.MaxSize)
+	case b.MaxGrowth > 0 && len(code) > 0 && float64(len(obCode)) > float64(len(code))*b.MaxGrowth:
+		return fmt.Sprintf("module grows %.1f times, limit %.1f", float64(len(obCode))/float64(len(code)), b.MaxGrowth)
+	case b.MaxGeneratedFunctions > 0 && c.generatedCount > b.MaxGeneratedFunctions:
+		return fmt.Sprintf("%d generated functions, limit %d", c.generatedCount, b.MaxGeneratedFunctions)
+	}
+
+	if b.MaxProcedureGrowth <= 0 || c.a == nil {
+		return ""
+	}
+
+	sizes := map[*ast.FunctionOrProcedure]int{}
+	for _, stm := range c.a.ModuleStatement.Body {
+		f, ok := stm.(*ast.FunctionOrProcedure)
+		if !ok {
+			continue
+		}
+
+		size := len(c.a.PrintStatementWithConf(f, budgetPrintConf))
+		if owner, ok := c.owners[f]; ok {
+			sizes[owner] += size
+		} else if _, ok := c.sizes[f]; ok {
+			sizes[f] += size
+		}
+	}
+
+	for f, before := range c.sizes {
+		if before > 0 && float64(sizes[f]) > float64(before)*b.MaxProcedureGrowth {
+			_, name := procedureDeclaration(c.source, c.sourceName(f.Name))
+			return fmt.Sprintf("method %s grows %.1f times, limit %.1f", name, float64(sizes[f])/float64(before), b.MaxProcedureGrowth)
+		}
+	}
+
+	return ""
+}
+
+var budgetPrintConf = ast.PrintConf{OneLine: true, Margin: 1}
+
+// measureProcedures запоминает размер методов модуля до обфускации
+func (c *Obfuscator) measureProcedures() {
+	c.sizes = map[*ast.FunctionOrProcedure]int{}
+	if c.conf.Budget.MaxProcedureGrowth <= 0 {
+		return
+	}
+
+	for _, stm := range c.a.ModuleStatement.Body {
+		if f, ok := stm.(*ast.FunctionOrProcedure); ok {
+			c.sizes[f] = len(c.a.PrintStatementWithConf(f, budgetPrintConf))
+		}
+	}
+}
+
+// canGenerate можно ли добавить еще n служебных функций. Если нельзя, добавляется предупреждение
+func (c *Obfuscator) canGenerate(n int) bool {
+	limit := c.conf.Budget.MaxGeneratedFunctions
+	if limit <= 0 || c.generatedCount+n <= limit {
+		return true
+	}
+
+	c.report(SeverityWarning, DiagBudget, nil, "generated functions limit %d is reached, expressions are not hidden behind fake functions", limit)
+	return false
+}
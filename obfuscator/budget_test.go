@@ -0,0 +1,79 @@
+package obfuscator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntensityScaled(t *testing.T) {
+	i := DefaultIntensity()
+	assert.Equal(t, i, i.scaled(1))
+
+	scaled := i.scaled(0.5)
+	assert.Equal(t, Level{Probability: ptr(0.5), MinDepth: 1, MaxDepth: 3}, scaled.CallStack)
+	assert.Equal(t, Level{Probability: ptr(0.25), MinDepth: 1, MaxDepth: 1, MaxNesting: ptr(1)}, scaled.Garbage)
+	assert.Equal(t, i.Names, scaled.Names)
+
+	scaled = Intensity{Conditions: Level{MaxNesting: ptr(1)}, Ternary: Level{MaxNesting: ptr(0)}}.scaled(0.25)
+	assert.Equal(t, 1, *scaled.Conditions.MaxNesting)
+	assert.Equal(t, 0, *scaled.Ternary.MaxNesting)
+}
+
+func TestBudget(t *testing.T) {
+	code := `Процедура Тест()
+	А = 1;
+	Б = "строка";
+КонецПроцедуры`
+
+	t.Run("generated functions", func(t *testing.T) {
+		obf := NewObfuscatory(context.Background(), Config{
+			CallStackHell: true,
+			Intensity:     Intensity{CallStack: Level{MinDepth: 3, MaxDepth: 4}},
+			Budget:        Budget{MaxGeneratedFunctions: 2},
+		})
+
+		result, err := obf.ObfuscateResult(code, ModuleInfo{})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.LessOrEqual(t, result.Report.GeneratedFunctions, 2)
+
+		obf.generatedCount = 1
+		assert.True(t, obf.canGenerate(1))
+		assert.False(t, obf.canGenerate(2))
+		assert.Contains(t, obf.Diagnostics(), Diagnostic{
+			Severity: SeverityWarning,
+			Code:     DiagBudget,
+			Message:  "generated functions limit 2 is reached, expressions are not hidden behind fake functions",
+		})
+	})
+	t.Run("growth", func(t *testing.T) {
+		conf, err := PresetParanoid.Config()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		// строки прячутся всегда, поэтому модуль не укладывается в ограничение даже с минимальной интенсивностью
+		conf.Budget = Budget{MaxGrowth: 1}
+		result, err := NewObfuscatory(context.Background(), conf).ObfuscateResult(code, ModuleInfo{})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		var exceeded bool
+		for _, d := range result.Diagnostics {
+			exceeded = exceeded || d.Code == DiagBudget && d.Severity == SeverityWarning
+		}
+		assert.True(t, exceeded)
+		assert.NotEmpty(t, result.Code)
+	})
+	t.Run("within budget", func(t *testing.T) {
+		obf := NewObfuscatory(context.Background(), Config{HideString: true, Budget: Budget{MaxGrowth: 100}})
+		result, err := obf.ObfuscateResult(code, ModuleInfo{})
+		assert.NoError(t, err)
+		assert.Empty(t, result.Diagnostics)
+	})
+}
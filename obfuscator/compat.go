@@ -0,0 +1,240 @@
+package obfuscator
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+	"github.com/pkg/errors"
+)
+
+//go:embed data/platform.json
+var platformData []byte
+
+// platformFunction доступность функции глобального контекста
+type platformFunction struct {
+	Names []string `json:"names"`
+
+	// Since версия платформы и режима совместимости, в которой появилась функция
+	Since string `json:"since"`
+
+	// Contexts где доступна функция, пусто - везде
+	Contexts []CompilationContext `json:"contexts,omitempty"`
+
+	// Unsafe функция недоступна в безопасном режиме
+	Unsafe bool `json:"unsafe,omitempty"`
+
+	// ExcludeClients клиентские приложения, в которых функция недоступна
+	ExcludeClients []ClientType `json:"excludeClients,omitempty"`
+}
+
+// platformTable доступность функций глобального контекста по версиям платформы и контекстам выполнения
+type platformTable struct {
+	Version   string             `json:"version"`
+	Platform  string             `json:"platform"`
+	Functions []platformFunction `json:"functions"`
+
+	names map[string]*platformFunction
+}
+
+var platformFunctions = loadPlatformTable()
+
+func loadPlatformTable() *platformTable {
+	t := &platformTable{names: map[string]*platformFunction{}}
+	if err := json.Unmarshal(platformData, t); err != nil {
+		panic("platform.json: " + err.Error())
+	}
+
+	for i := range t.Functions {
+		f := &t.Functions[i]
+		if _, err := parseVersion(f.Since); err != nil {
+			panic("platform.json: " + err.Error())
+		}
+		for _, name := range f.Names {
+			t.names[strings.ToLower(name)] = f
+		}
+	}
+
+	return t
+}
+
+// PlatformTableVersion версия встроенной таблицы функций глобального контекста и версия платформы, по которой она составлена
+func PlatformTableVersion() (version, platform string) {
+	return platformFunctions.Version, platformFunctions.Platform
+}
+
+// unsupported почему функция недоступна на target в контекстах contexts, пустая строка - функция доступна.
+// Если контекст неизвестен (nil), он не проверяется
+func (f *platformFunction) unsupported(target Target, contexts []CompilationContext) string {
+	if !target.atLeast(f.Since) {
+		return fmt.Sprintf("requires platform %s", f.Since)
+	}
+	if f.Unsafe && target.SafeMode {
+		return "is not available in safe mode"
+	}
+	for _, client := range f.ExcludeClients {
+		if target.hasClient(client) {
+			return fmt.Sprintf("is not available in %s client", client)
+		}
+	}
+	if len(f.Contexts) == 0 {
+		return ""
+	}
+
+	for _, ctx := range contexts {
+		available := false
+		for _, allowed := range f.Contexts {
+			available = available || allowed == ctx
+		}
+		if !available {
+			return fmt.Sprintf("is not available in %s context", ctx)
+		}
+	}
+
+	return ""
+}
+
+// compatIssue вызов функции, недоступной на целевой платформе
+type compatIssue struct {
+	name, reason string
+}
+
+func (i compatIssue) key() string {
+	return strings.ToLower(i.name) + " " + i.reason
+}
+
+// checkSourceCompatibility проверяет исходный модуль: нарушения попадают в диагностики и запоминаются,
+// чтобы в результате сообщать только о вызовах, которые добавил обфускатор
+func (c *Obfuscator) checkSourceCompatibility() {
+	c.compat = map[string]struct{}{}
+	if !c.conf.Target.declared() {
+		return
+	}
+
+	c.walkCompatibility(c.a.ModuleStatement.Body, func(f *ast.FunctionOrProcedure, issue compatIssue) {
+		c.compat[issue.key()] = struct{}{}
+		c.report(SeverityError, DiagCompatibility, f, "%s %s", issue.name, issue.reason)
+	})
+}
+
+// checkCompatibility проверяет результат вместе со строками Выполнить()/Вычислить(). Возвращает ошибку,
+// если исходный или сгенерированный код вызывает функции, недоступные на Config.Target. Каждое нарушение
+// перед этим попадает в диагностики
+func (c *Obfuscator) checkCompatibility() error {
+	if !c.conf.Target.declared() {
+		return nil
+	}
+
+	var generated []compatIssue
+	add := func(issue compatIssue) {
+		if _, ok := c.compat[issue.key()]; ok {
+			return
+		}
+
+		c.compat[issue.key()] = struct{}{}
+		generated = append(generated, issue)
+		c.report(SeverityError, DiagCompatibility, nil, "generated code calls %s, which %s", issue.name, issue.reason)
+	}
+
+	c.walkCompatibility(c.a.ModuleStatement.Body, func(_ *ast.FunctionOrProcedure, issue compatIssue) { add(issue) })
+	for _, eval := range c.evals {
+		code := eval.code
+		if eval.expression {
+			code = "_ = " + code + ";"
+		}
+
+		a := ast.NewAST(code)
+		if err := a.Parse(); err != nil {
+			err = errors.Wrapf(err, "eval string %q does not parse", eval.code)
+			c.report(SeverityError, DiagValidation, nil, "%v", err)
+			return err
+		}
+		for _, issue := range c.compatIssues(a.ModuleStatement.Body, nil) {
+			add(issue)
+		}
+	}
+
+	switch {
+	case len(generated) > 0:
+		return errors.Errorf("generated code calls %s, which %s", generated[0].name, generated[0].reason)
+	case len(c.compat) > 0:
+		return errors.Errorf("source code calls %d functions that are not available on the target platform", len(c.compat))
+	}
+
+	return nil
+}
+
+// walkCompatibility передает fn недоступные вызовы вместе с методом, в котором они находятся (nil - основная программа модуля)
+func (c *Obfuscator) walkCompatibility(body ast.Statements, fn func(f *ast.FunctionOrProcedure, issue compatIssue)) {
+	current := c.current
+	defer func() { c.current = current }()
+
+	for _, stm := range body {
+		f, _ := stm.(*ast.FunctionOrProcedure)
+
+		var directive string
+		if f != nil {
+			directive = f.Directive
+		}
+
+		c.current = f
+		for _, issue := range c.compatIssues(stm, c.executionContext(directive)) {
+			fn(f, issue)
+		}
+	}
+}
+
+// compatIssues вызовы функций глобального контекста, недоступных на целевой платформе в контекстах contexts
+func (c *Obfuscator) compatIssues(stm ast.Statement, contexts []CompilationContext) []compatIssue {
+	var issues []compatIssue
+	globalCalls(reflect.ValueOf(stm), func(name string) {
+		f, ok := platformFunctions.names[strings.ToLower(name)]
+		if !ok {
+			return
+		}
+		if reason := f.unsupported(c.conf.Target, contexts); reason != "" {
+			issues = append(issues, compatIssue{name: name, reason: reason})
+		}
+	})
+
+	return issues
+}
+
+// globalCalls передает fn имена вызываемых функций глобального контекста. Методы объектов в цепочках
+// (Запрос.Выполнить()) пропускаются, проверяются только их параметры
+func globalCalls(v reflect.Value, fn func(name string)) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			globalCalls(v.Elem(), fn)
+		}
+	case reflect.Struct:
+		if !v.CanInterface() {
+			return
+		}
+
+		switch stm := v.Interface().(type) {
+		case ast.MethodStatement:
+			fn(stm.Name)
+		case ast.CallChainStatement:
+			if m, ok := stm.Unit.(ast.MethodStatement); ok {
+				globalCalls(reflect.ValueOf(m.Param), fn)
+				globalCalls(reflect.ValueOf(stm.Call), fn)
+				return
+			}
+		}
+
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				globalCalls(v.Field(i), fn)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			globalCalls(v.Index(i), fn)
+		}
+	}
+}
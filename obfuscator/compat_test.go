@@ -0,0 +1,125 @@
+package obfuscator
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlatformTable(t *testing.T) {
+	version, platform := PlatformTableVersion()
+	assert.NotEmpty(t, version)
+	assert.NotEmpty(t, platform)
+
+	// функции, которые использует декодер строк и фейковые вызовы, есть в таблице
+	for _, name := range []string{"ПобитовоеИНе", "ПобитовыйСдвигВлево", "Base64Значение", "ПолучитьСтрокуИзДвоичныхДанных", "bitwiseor"} {
+		assert.Contains(t, platformFunctions.names, strings.ToLower(name))
+	}
+
+	bitwise := platformFunctions.names["побитовоеи"]
+	assert.Equal(t, "requires platform 8.3.11", bitwise.unsupported(Target{PlatformVersion: "8.3.10"}, nil))
+	assert.Equal(t, "requires platform 8.3.11", bitwise.unsupported(Target{PlatformVersion: "8.3.12", CompatibilityMode: "8.3.10"}, nil))
+	assert.Empty(t, bitwise.unsupported(Target{PlatformVersion: "8.3.11"}, nil))
+
+	eval := platformFunctions.names["выполнить"]
+	assert.Equal(t, "is not available in safe mode", eval.unsupported(Target{SafeMode: true}, nil))
+	assert.Equal(t, "is not available in mobile client", eval.unsupported(Target{Clients: []ClientType{ClientMobile}}, nil))
+
+	form := platformFunctions.names["открытьформу"]
+	assert.Equal(t, "is not available in server context", form.unsupported(Target{}, []CompilationContext{ContextServer}))
+	assert.Empty(t, form.unsupported(Target{}, []CompilationContext{ContextClient}))
+	assert.Empty(t, form.unsupported(Target{}, nil))
+}
+
+func TestGlobalCalls(t *testing.T) {
+	body := ast.Statements{
+		// Запрос.Выполнить() - метод объекта, а не функция глобального контекста
+		ast.CallChainStatement{
+			Unit: ast.MethodStatement{Name: "Выполнить", Param: ast.ExprStatements{Statements: ast.Statements{ast.MethodStatement{Name: "СтрНайти"}}}},
+			Call: ast.VarStatement{Name: "Запрос"},
+		},
+		&ast.ExpStatement{Operation: ast.OpEq, Left: ast.VarStatement{Name: "А"}, Right: ast.MethodStatement{Name: "ПобитовоеИ"}},
+	}
+
+	var names []string
+	globalCalls(reflect.ValueOf(body), func(name string) { names = append(names, name) })
+	assert.Equal(t, []string{"СтрНайти", "ПобитовоеИ"}, names)
+}
+
+// strFindTransform добавляет в методы вызов СтрНайти, которой нет до 8.3.6
+type strFindTransform struct{}
+
+func (strFindTransform) Name() string {
+	return "test-str-find"
+}
+
+func (strFindTransform) Apply(_ *TransformEnv, module *ast.ModuleStatement) error {
+	for _, item := range module.Body {
+		if f, ok := item.(*ast.FunctionOrProcedure); ok {
+			f.Body = append(f.Body, ast.MethodStatement{
+				Name:  "СтрНайти",
+				Param: ast.ExprStatements{Statements: ast.Statements{"строка", "т"}},
+			})
+		}
+	}
+
+	return nil
+}
+
+func TestObfuscateStringDigits(t *testing.T) {
+	obf := NewObfuscatory(context.Background(), Config{Target: Target{PlatformVersion: "8.3.8"}})
+	encoded := obf.obfuscateString("Ок", 10)
+	assert.Equal(t, "0001064"+"0001092", encoded)
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	code := `&НаСервере
+Процедура Тест()
+	Сообщить(СтрНайти("строка", "т"));
+КонецПроцедуры`
+
+	t.Run("source", func(t *testing.T) {
+		obf := NewObfuscatory(context.Background(), Config{Target: Target{CompatibilityMode: "8.3.5"}})
+		result, err := obf.ObfuscateResult(code, ModuleInfo{Kind: ModuleForm})
+		assert.EqualError(t, err, "compatibility error: source code calls 1 functions that are not available on the target platform")
+		assert.Contains(t, result.Diagnostics, Diagnostic{
+			Severity:  SeverityError,
+			Code:      DiagCompatibility,
+			Message:   "СтрНайти requires platform 8.3.6",
+			Line:      2,
+			Procedure: "Тест",
+		})
+	})
+	t.Run("generated", func(t *testing.T) {
+		RegisterTransform(strFindTransform{})
+
+		obf := NewObfuscatory(context.Background(), Config{Passes: []string{strFindTransform{}.Name()}, Target: Target{PlatformVersion: "8.3.5"}})
+		_, err := obf.ObfuscateResult(`Процедура Тест()
+	Сообщить("строка");
+КонецПроцедуры`, ModuleInfo{})
+		assert.EqualError(t, err, "compatibility error: generated code calls СтрНайти, which requires platform 8.3.6")
+	})
+	t.Run("strings before 8.3.9", func(t *testing.T) {
+		// ПолучитьСтрокуИзДвоичныхДанных нет до 8.3.9, декодер читает коды символов числами
+		obf := NewObfuscatory(context.Background(), Config{HideString: true, Target: Target{PlatformVersion: "8.3.8"}})
+		result, err := obf.ObfuscateResult(`Процедура Тест()
+	Сообщить("строка");
+КонецПроцедуры`, ModuleInfo{})
+		if assert.NoError(t, err) {
+			assert.NotContains(t, result.Code, `"строка"`)
+			assert.NotContains(t, result.Code, "ПолучитьСтрокуИзДвоичныхДанных")
+			assert.Contains(t, result.Code, "Сред(")
+			assert.False(t, result.HasErrors())
+		}
+	})
+	t.Run("supported", func(t *testing.T) {
+		obf := NewObfuscatory(context.Background(), Config{HideString: true, AppendGarbage: true, Target: Target{PlatformVersion: "8.3.10"}})
+		result, err := obf.ObfuscateResult(code, ModuleInfo{Kind: ModuleForm})
+		assert.NoError(t, err)
+		assert.Empty(t, result.Diagnostics)
+	})
+}
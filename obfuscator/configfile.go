@@ -0,0 +1,247 @@
+package obfuscator
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Settings настройки из файла конфигурации. Заданные поля переопределяют настройки пресета
+// и настройки с более высокого уровня (глобальные -> правила по порядку)
+type Settings struct {
+	Preset           Preset      `yaml:"preset,omitempty" json:"preset,omitempty"`
+	RepExpByTernary  *bool       `yaml:"repExpByTernary,omitempty" json:"repExpByTernary,omitempty"`
+	RepLoopByGoto    *bool       `yaml:"repLoopByGoto,omitempty" json:"repLoopByGoto,omitempty"`
+	RepExpByEval     *bool       `yaml:"repExpByEval,omitempty" json:"repExpByEval,omitempty"`
+	HideString       *bool       `yaml:"hideString,omitempty" json:"hideString,omitempty"`
+	ChangeConditions *bool       `yaml:"changeConditions,omitempty" json:"changeConditions,omitempty"`
+	AppendGarbage    *bool       `yaml:"appendGarbage,omitempty" json:"appendGarbage,omitempty"`
+	ExceptionFlow    *bool       `yaml:"exceptionFlow,omitempty" json:"exceptionFlow,omitempty"`
+	CallStackHell    *bool       `yaml:"callStackHell,omitempty" json:"callStackHell,omitempty"`
+	RenameMethods    *bool       `yaml:"renameMethods,omitempty" json:"renameMethods,omitempty"`
+	RenameVariables  *bool       `yaml:"renameVariables,omitempty" json:"renameVariables,omitempty"`
+	Regions          RegionsMode `yaml:"regions,omitempty" json:"regions,omitempty"`
+	PreservedNames   []string    `yaml:"preservedNames,omitempty" json:"preservedNames,omitempty"`
+	Passes           []string    `yaml:"passes,omitempty" json:"passes,omitempty"`
+	Intensity        Intensity   `yaml:"intensity,omitempty" json:"intensity,omitempty"`
+	Budget           Budget      `yaml:"budget,omitempty" json:"budget,omitempty"`
+	Performance      *bool       `yaml:"performance,omitempty" json:"performance,omitempty"`
+	HotMethods       []string    `yaml:"hotMethods,omitempty" json:"hotMethods,omitempty"`
+	Target           *Target     `yaml:"target,omitempty" json:"target,omitempty"`
+}
+
+// Rule настройки для модулей, путь которых подходит под шаблон.
+// В шаблоне поддерживаются * и ? в пределах одного каталога и ** для любого количества каталогов,
+// шаблон каталога действует на все модули внутри него
+type Rule struct {
+	Path     string `yaml:"path" json:"path"`
+	Settings `yaml:",inline"`
+}
+
+// FileConfig содержимое файла конфигурации
+//
+//	preset: balanced
+//	exclude:
+//	  - CommonModules/Служебный*
+//	rules:
+//	  - path: CommonModules/*Клиент*
+//	    repExpByEval: false
+//	  - path: DataProcessors/Лицензирование/**
+//	    preset: paranoid
+type FileConfig struct {
+	Settings `yaml:",inline"`
+
+	// Exclude модули, которые не нужно обфусцировать
+	Exclude []string `yaml:"exclude,omitempty" json:"exclude,omitempty"`
+
+	// Rules переопределение настроек по пути модуля, применяются по порядку
+	Rules []Rule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// LoadConfigFile читает файл конфигурации, формат определяется по расширению (.json, иначе yaml)
+func LoadConfigFile(filePath string) (*FileConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "read config error")
+	}
+
+	conf, err := ParseConfig(data, strings.EqualFold(filepath.Ext(filePath), ".json"))
+	return conf, errors.Wrapf(err, "config %q", filePath)
+}
+
+// ParseConfig разбирает файл конфигурации, неизвестные ключи считаются ошибкой
+func ParseConfig(data []byte, isJSON bool) (*FileConfig, error) {
+	conf := new(FileConfig)
+
+	if isJSON {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(conf); err != nil {
+			return nil, errors.Wrap(err, "parse json error")
+		}
+	} else if len(bytes.TrimSpace(data)) > 0 {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(conf); err != nil {
+			return nil, errors.Wrap(err, "parse yaml error")
+		}
+	}
+
+	return conf, conf.validate()
+}
+
+func (f *FileConfig) validate() error {
+	if _, err := f.Settings.apply(Config{}); err != nil {
+		return err
+	}
+
+	for _, pattern := range f.Exclude {
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return errors.Wrapf(err, "exclude %q", pattern)
+		}
+	}
+
+	for i, r := range f.Rules {
+		if r.Path == "" {
+			return errors.Errorf("rule #%d: path is empty", i+1)
+		}
+		if _, err := path.Match(strings.ReplaceAll(r.Path, "**", "*"), ""); err != nil {
+			return errors.Wrapf(err, "rule %q", r.Path)
+		}
+		if _, err := r.Settings.apply(Config{}); err != nil {
+			return errors.Wrapf(err, "rule %q", r.Path)
+		}
+	}
+
+	return nil
+}
+
+// ConfigFor настройки для модуля по его пути относительно корня выгрузки.
+// Если модуль исключен, возвращается false
+func (f *FileConfig) ConfigFor(modulePath string) (Config, bool, error) {
+	modulePath = filepath.ToSlash(modulePath)
+
+	for _, pattern := range f.Exclude {
+		if matchPath(pattern, modulePath) {
+			return Config{}, false, nil
+		}
+	}
+
+	conf, err := f.Settings.apply(Config{})
+	if err != nil {
+		return conf, false, err
+	}
+
+	for _, r := range f.Rules {
+		if !matchPath(r.Path, modulePath) {
+			continue
+		}
+
+		if conf, err = r.Settings.apply(conf); err != nil {
+			return conf, false, errors.Wrapf(err, "rule %q", r.Path)
+		}
+	}
+
+	return conf, true, nil
+}
+
+// apply накладывает настройки на conf, пресет заменяет все заданное ранее
+func (s *Settings) apply(conf Config) (Config, error) {
+	if s.Preset != "" {
+		var err error
+		if conf, err = s.Preset.Config(); err != nil {
+			return conf, err
+		}
+	}
+
+	for _, f := range []struct {
+		value  *bool
+		target *bool
+	}{
+		{s.RepExpByTernary, &conf.RepExpByTernary},
+		{s.RepLoopByGoto, &conf.RepLoopByGoto},
+		{s.RepExpByEval, &conf.RepExpByEval},
+		{s.HideString, &conf.HideString},
+		{s.ChangeConditions, &conf.ChangeConditions},
+		{s.AppendGarbage, &conf.AppendGarbage},
+		{s.ExceptionFlow, &conf.ExceptionFlow},
+		{s.CallStackHell, &conf.CallStackHell},
+		{s.RenameMethods, &conf.RenameMethods},
+		{s.RenameVariables, &conf.RenameVariables},
+		{s.Performance, &conf.Performance},
+	} {
+		if f.value != nil {
+			*f.target = *f.value
+		}
+	}
+
+	if s.Regions != RegionsDefault {
+		if err := s.Regions.validate(); err != nil {
+			return conf, err
+		}
+		conf.Regions = s.Regions
+	}
+
+	conf.PreservedNames = append(conf.PreservedNames, s.PreservedNames...)
+	conf.HotMethods = append(conf.HotMethods, s.HotMethods...)
+	if s.Passes != nil {
+		conf.Passes = append([]string{}, s.Passes...)
+	}
+	for _, name := range conf.Passes {
+		if _, ok := LookupTransform(name); !ok {
+			return conf, errors.Errorf("unknown transform %q", name)
+		}
+	}
+
+	conf.Intensity = conf.Intensity.merge(s.Intensity)
+	conf.Budget = conf.Budget.merge(s.Budget)
+	if s.Target != nil {
+		if err := s.Target.validate(); err != nil {
+			return conf, err
+		}
+		conf.Target = *s.Target
+	}
+	return conf, nil
+}
+
+// matchPath подходит ли путь (или один из его каталогов) под шаблон
+func matchPath(pattern, p string) bool {
+	patternParts := strings.Split(strings.Trim(filepath.ToSlash(pattern), "/"), "/")
+	pathParts := strings.Split(strings.Trim(p, "/"), "/")
+
+	for i := len(pathParts); i > 0; i-- {
+		if matchParts(patternParts, pathParts[:i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchParts(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchParts(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(parts) == 0 {
+		return false
+	}
+
+	ok, err := path.Match(pattern[0], parts[0])
+	return err == nil && ok && matchParts(pattern[1:], parts[1:])
+}
@@ -0,0 +1,87 @@
+package obfuscator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConfig(t *testing.T) {
+	yamlConf := `
+preset: balanced
+intensity:
+  garbage:
+    probability: 0.2
+exclude:
+  - CommonModules/Служебный*
+rules:
+  - path: CommonModules/*Клиент*
+    repExpByEval: false
+    hideString: false
+    intensity:
+      garbage:
+        probability: 0
+  - path: DataProcessors/Лицензирование/**
+    preset: paranoid
+`
+	jsonConf := `{
+  "preset": "balanced",
+  "intensity": {"garbage": {"probability": 0.2}},
+  "exclude": ["CommonModules/Служебный*"],
+  "rules": [
+    {"path": "CommonModules/*Клиент*", "repExpByEval": false, "hideString": false, "intensity": {"garbage": {"probability": 0}}},
+    {"path": "DataProcessors/Лицензирование/**", "preset": "paranoid"}
+  ]
+}`
+
+	for name, data := range map[string]string{"yaml": yamlConf, "json": jsonConf} {
+		t.Run(name, func(t *testing.T) {
+			fileConf, err := ParseConfig([]byte(data), name == "json")
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			conf, ok, err := fileConf.ConfigFor("Documents/Заказ/Ext/ObjectModule.bsl")
+			if assert.NoError(t, err) && assert.True(t, ok) {
+				assert.True(t, conf.HideString)
+				assert.False(t, conf.RepExpByEval)
+				assert.Equal(t, ptr(0.2), conf.Intensity.Garbage.Probability)
+			}
+
+			conf, ok, err = fileConf.ConfigFor("CommonModules/ОбщийКлиент/Ext/Module.bsl")
+			if assert.NoError(t, err) && assert.True(t, ok) {
+				assert.False(t, conf.HideString)
+				assert.True(t, conf.RepLoopByGoto)
+				// нулевая вероятность задана явно и не заменяется значением по умолчанию
+				assert.Equal(t, 0.0, conf.Intensity.withDefaults().Garbage.probability())
+			}
+
+			conf, ok, err = fileConf.ConfigFor("DataProcessors/Лицензирование/Forms/Форма/Ext/Form/Module.bsl")
+			if assert.NoError(t, err) && assert.True(t, ok) {
+				assert.True(t, conf.RepExpByEval)
+				assert.True(t, conf.CallStackHell)
+			}
+
+			_, ok, err = fileConf.ConfigFor("CommonModules/СлужебныйСервер/Ext/Module.bsl")
+			assert.NoError(t, err)
+			assert.False(t, ok)
+		})
+	}
+
+	_, err := ParseConfig([]byte("hideStrings: true"), false)
+	assert.Error(t, err)
+
+	_, err = ParseConfig([]byte(`{"hideStrings": true}`), true)
+	assert.Error(t, err)
+
+	_, err = ParseConfig([]byte("preset: unknown"), false)
+	assert.Error(t, err)
+}
+
+func TestMatchPath(t *testing.T) {
+	assert.True(t, matchPath("CommonModules/*Клиент*", "CommonModules/ОбщийКлиент/Ext/Module.bsl"))
+	assert.True(t, matchPath("**/Forms/**", "DataProcessors/Обработка/Forms/Форма/Ext/Form/Module.bsl"))
+	assert.True(t, matchPath("DataProcessors/**/Module.bsl", "DataProcessors/Обработка/Ext/Module.bsl"))
+	assert.False(t, matchPath("CommonModules/*Клиент", "CommonModules/ОбщийКлиентСервер/Ext/Module.bsl"))
+	assert.False(t, matchPath("Documents/*", "CommonModules/Документы/Ext/Module.bsl"))
+}
@@ -0,0 +1,106 @@
+package obfuscator
+
+import (
+	"strings"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+)
+
+// директивы компиляции и контексты, в которых выполняется метод с директивой
+var directiveContexts = map[string][]CompilationContext{
+	"&наклиенте": {ContextClient},
+	"&atclient":  {ContextClient},
+	"&насервере": {ContextServer},
+	"&atserver":  {ContextServer},
+	"&насерверебезконтекста":          {ContextServer},
+	"&atservernocontext":              {ContextServer},
+	"&наклиентенасервере":             {ContextClient, ContextServer},
+	"&atclientatserver":               {ContextClient, ContextServer},
+	"&наклиентенасерверебезконтекста": {ContextClient, ContextServer},
+	"&atclientatservernocontext":      {ContextClient, ContextServer},
+}
+
+// executionContext где выполняется метод с указанной директивой.
+// Метод формы без директивы выполняется на сервере, метод модуля без директив - там, где компилируется модуль,
+// условия препроцессора вокруг метода сужают контекст. nil - контекст неизвестен
+func (c *Obfuscator) executionContext(directive string) []CompilationContext {
+	contexts := c.directiveContext(directive)
+
+	// метод внутри #Если Сервер Тогда выполняется только на сервере
+	for _, block := range conditions(c.placement[c.current]) {
+		if narrow := conditionContexts(block.text); narrow != nil {
+			contexts = intersectContexts(contexts, narrow)
+		}
+	}
+
+	return contexts
+}
+
+func (c *Obfuscator) directiveContext(directive string) []CompilationContext {
+	if contexts, ok := directiveContexts[strings.ToLower(strings.TrimSpace(directive))]; ok {
+		return contexts
+	}
+
+	switch {
+	case c.module.Kind == ModuleForm || c.module.Kind == ModuleCommand:
+		return []CompilationContext{ContextServer}
+	case c.module.Kind == ModuleUnknown && len(c.module.CompilationContext) == 0:
+		return nil
+	default:
+		module, _ := c.module.withDefaults()
+		return module.CompilationContext
+	}
+}
+
+// directive директива служебной функции (декодер, фейковые функции), которую вызывает метод с указанной директивой.
+// Служебные функции не используют контекст формы, поэтому серверная функция объявляется без контекста
+// и доступна как из &НаСервере, так и из &НаСервереБезКонтекста. В модулях без директив компиляции директива не указывается
+func (c *Obfuscator) directive(directive string) string {
+	if !c.module.directivesAllowed() {
+		return ""
+	}
+
+	if c.directiveContext(directive) == nil {
+		return directive
+	}
+	contexts := c.executionContext(directive)
+
+	client, server := false, false
+	for _, ctx := range contexts {
+		client = client || ctx == ContextClient
+		server = server || ctx == ContextServer || ctx == ContextExternalConnection
+	}
+
+	english := strings.HasPrefix(strings.ToLower(strings.TrimSpace(directive)), "&at")
+	command := c.module.Kind == ModuleCommand
+	switch {
+	case client && server:
+		return ast.IF(english, ast.IF(command, "&AtClientAtServer", "&AtClientAtServerNoContext"), ast.IF(command, "&НаКлиентеНаСервере", "&НаКлиентеНаСервереБезКонтекста"))
+	case client:
+		return ast.IF(english, "&AtClient", "&НаКлиенте")
+	default:
+		return ast.IF(english, ast.IF(command, "&AtServer", "&AtServerNoContext"), ast.IF(command, "&НаСервере", "&НаСервереБезКонтекста"))
+	}
+}
+
+// intersectContexts пересечение контекстов, nil - любой контекст
+func intersectContexts(a, b []CompilationContext) []CompilationContext {
+	if a == nil {
+		return b
+	}
+
+	var result []CompilationContext
+	for _, x := range a {
+		for _, y := range b {
+			if x == y || (x == ContextExternalConnection && y == ContextServer) {
+				result = append(result, x)
+			}
+		}
+	}
+
+	if len(result) == 0 {
+		return a
+	}
+
+	return result
+}
@@ -0,0 +1,196 @@
+package obfuscator
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+)
+
+// Severity важность диагностики
+type Severity string
+
+const (
+	// SeverityError результат нельзя использовать
+	SeverityError Severity = "error"
+	// SeverityWarning часть кода не преобразована
+	SeverityWarning Severity = "warning"
+	// SeverityInfo справочное сообщение
+	SeverityInfo Severity = "info"
+)
+
+// коды диагностик
+const (
+	DiagConditionGenerator = "condition-generator" // не удалось сгенерировать фиктивное условие
+	DiagConditionParse     = "condition-parse"     // не удалось разобрать сгенерированное условие
+	DiagRandom             = "random"              // криптографический генератор недоступен, использован math/rand
+	DiagGotoSkipped        = "goto-skipped"        // цикл не заменен на Перейти
+	DiagExceptionsSkipped  = "exceptions-skipped"  // условия метода не переведены на исключения
+	DiagAwaitSkipped       = "await-skipped"       // асинхронный метод с Ждать выведен без изменений
+	DiagBudget             = "budget"              // интенсивность уменьшена или результат не уложился в Config.Budget
+	DiagTarget             = "target"              // проход отключен или ограничен из-за Config.Target
+	DiagCompatibility      = "compatibility"       // код вызывает функцию, недоступную на Config.Target
+	DiagValidation         = "validation"          // результат не прошел проверку и не может быть использован
+)
+
+// Diagnostic сообщение об ошибке или о коде, который не был преобразован
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+
+	// Path путь модуля из ModuleInfo, Line - строка объявления метода в исходном модуле (с 1)
+	Path string `json:"path,omitempty"`
+	Line int    `json:"line,omitempty"`
+
+	// Procedure метод, пусто для кода основной программы и модуля в целом
+	Procedure string `json:"procedure,omitempty"`
+}
+
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	if d.Path != "" {
+		b.WriteString(d.Path)
+		if d.Line > 0 {
+			fmt.Fprintf(&b, ":%d", d.Line)
+		}
+		b.WriteString(": ")
+	}
+
+	fmt.Fprintf(&b, "%s: %s [%s]", d.Severity, d.Message, d.Code)
+	if d.Procedure != "" {
+		fmt.Fprintf(&b, " (%s)", d.Procedure)
+	}
+
+	return b.String()
+}
+
+// Result результат обфускации модуля
+type Result struct {
+	Code        string
+	Diagnostics []Diagnostic
+	Report      Report
+}
+
+// HasErrors есть ли диагностики с важностью SeverityError
+func (r Result) HasErrors() bool {
+	return hasErrors(r.Diagnostics)
+}
+
+func hasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Logger получает диагностики по мере их появления. Может вызываться из нескольких горутин
+type Logger interface {
+	Log(d Diagnostic)
+}
+
+// LoggerFunc функция как Logger
+type LoggerFunc func(d Diagnostic)
+
+func (f LoggerFunc) Log(d Diagnostic) {
+	f(d)
+}
+
+// WriterLogger пишет диагностики в w по одной на строку
+func WriterLogger(w io.Writer) Logger {
+	var mx sync.Mutex
+	return LoggerFunc(func(d Diagnostic) {
+		mx.Lock()
+		defer mx.Unlock()
+
+		fmt.Fprintln(w, d)
+	})
+}
+
+// diagnostics диагностики текущего запуска
+type diagnostics struct {
+	mx    sync.Mutex
+	items []Diagnostic
+	seen  map[Diagnostic]struct{}
+}
+
+func (d *diagnostics) reset() []Diagnostic {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	items := d.items
+	d.items, d.seen = nil, map[Diagnostic]struct{}{}
+	return items
+}
+
+// report добавляет диагностику и передает ее логгеру, одинаковые диагностики не повторяются
+func (c *Obfuscator) report(severity Severity, code string, f *ast.FunctionOrProcedure, format string, args ...interface{}) {
+	d := Diagnostic{
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+		Path:     c.module.Path,
+	}
+	if f != nil {
+		d.Line, d.Procedure = procedureDeclaration(c.source, c.sourceName(f.Name))
+	}
+
+	c.diag.mx.Lock()
+	if _, ok := c.diag.seen[d]; ok {
+		c.diag.mx.Unlock()
+		return
+	}
+	if c.diag.seen == nil {
+		c.diag.seen = map[Diagnostic]struct{}{}
+	}
+	c.diag.seen[d] = struct{}{}
+	c.diag.items = append(c.diag.items, d)
+	c.diag.mx.Unlock()
+
+	if c.conf.Logger != nil {
+		c.conf.Logger.Log(d)
+	}
+}
+
+// Diagnostics диагностики последнего вызова Obfuscate/ObfuscateModule
+func (c *Obfuscator) Diagnostics() []Diagnostic {
+	c.diag.mx.Lock()
+	defer c.diag.mx.Unlock()
+
+	return append([]Diagnostic{}, c.diag.items...)
+}
+
+var procedureDecl = regexp.MustCompile(`(?im)^[ \t]*(?:асинх[ \t]+|async[ \t]+)?(?:процедура|функция|procedure|function)[ \t]+([\p{L}\d_]+)[ \t]*\(`)
+
+// sourceName имя метода до переименования
+func (c *Obfuscator) sourceName(name string) string {
+	for old, renamed := range c.renames {
+		if strings.EqualFold(renamed, name) {
+			return old
+		}
+	}
+
+	return name
+}
+
+// procedureDeclaration строка объявления метода в исходном коде и имя, как оно там написано.
+// Для служебных методов и основной программы строка 0
+func procedureDeclaration(source, name string) (int, string) {
+	if name == "" {
+		return 0, ""
+	}
+
+	for _, m := range procedureDecl.FindAllStringSubmatchIndex(source, -1) {
+		if strings.EqualFold(source[m[2]:m[3]], name) {
+			return strings.Count(source[:m[0]], "\n") + 1, source[m[2]:m[3]]
+		}
+	}
+
+	return 0, name
+}
@@ -0,0 +1,105 @@
+package obfuscator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiagnostics(t *testing.T) {
+	code := `Процедура Служебная()
+КонецПроцедуры
+
+Процедура Тест(Коллекция)
+	Для Каждого Элемент Из Коллекция Цикл
+		Попытка
+			Прервать;
+		Исключение
+			Если Элемент = 1 Тогда
+				Сообщить(ОписаниеОшибки());
+			КонецЕсли;
+		КонецПопытки;
+	КонецЦикла;
+КонецПроцедуры`
+
+	var logged []Diagnostic
+	obf := NewObfuscatory(context.Background(), Config{
+		RepLoopByGoto: true,
+		ExceptionFlow: true,
+		Intensity:     Intensity{Goto: Level{Probability: ptr(1.0)}, Exceptions: Level{Probability: ptr(1.0)}},
+		Logger:        LoggerFunc(func(d Diagnostic) { logged = append(logged, d) }),
+	})
+
+	result, err := obf.ObfuscateResult(code, ModuleInfo{Path: "CommonModules/Тест/Ext/Module.bsl"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.False(t, result.HasErrors())
+	assert.Equal(t, result.Diagnostics, logged)
+	assert.Contains(t, result.Diagnostics, Diagnostic{
+		Severity:  SeverityWarning,
+		Code:      DiagGotoSkipped,
+		Message:   "loop is not replaced with Перейти: Прервать or Продолжить inside Попытка",
+		Path:      "CommonModules/Тест/Ext/Module.bsl",
+		Line:      4,
+		Procedure: "Тест",
+	})
+	assert.Contains(t, result.Diagnostics, Diagnostic{
+		Severity:  SeverityWarning,
+		Code:      DiagExceptionsSkipped,
+		Message:   "method reads current exception, conditions are not passed through exceptions",
+		Path:      "CommonModules/Тест/Ext/Module.bsl",
+		Line:      4,
+		Procedure: "Тест",
+	})
+	assert.Equal(t, result.Diagnostics, obf.Diagnostics())
+
+	// диагностики относятся к последнему запуску
+	_, err = obf.Obfuscate(`Процедура Тест()
+КонецПроцедуры`)
+	assert.NoError(t, err)
+	assert.Empty(t, obf.Diagnostics())
+}
+
+func TestDiagnosticString(t *testing.T) {
+	d := Diagnostic{Severity: SeverityWarning, Code: DiagGotoSkipped, Message: "loop", Path: "Module.bsl", Line: 4, Procedure: "Тест"}
+	assert.Equal(t, "Module.bsl:4: warning: loop [goto-skipped] (Тест)", d.String())
+
+	d = Diagnostic{Severity: SeverityError, Code: DiagRandom, Message: "rand"}
+	assert.Equal(t, "error: rand [random]", d.String())
+}
+
+func TestProcedureDeclaration(t *testing.T) {
+	source := `Перем А;
+
+&НаСервере
+Асинх Функция Получить(Знач Б) Экспорт
+КонецФункции
+
+	процедура тест()
+КонецПроцедуры`
+
+	line, name := procedureDeclaration(source, "получить")
+	assert.Equal(t, 4, line)
+	assert.Equal(t, "Получить", name)
+
+	line, name = procedureDeclaration(source, "Тест")
+	assert.Equal(t, 7, line)
+	assert.Equal(t, "тест", name)
+
+	line, _ = procedureDeclaration(source, "Нет")
+	assert.Equal(t, 0, line)
+}
+
+func TestGenErrors(t *testing.T) {
+	var e genErrors
+	e.add(errors.New("первая"))
+	e.add(errors.New("вторая"))
+
+	// ошибки не теряются и забираются один раз
+	assert.Len(t, e.reset(), 2)
+	assert.Len(t, e.reset(), 0)
+}
@@ -0,0 +1,168 @@
+package obfuscator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LazarenkoA/Obfuscator-1C/interpreter"
+	"github.com/stretchr/testify/assert"
+)
+
+// модуль для проверки, что обфусцированный код вычисляет то же, что исходный
+const equivalenceModule = `Функция Сумма(Коллекция) Экспорт
+	Результат = 0;
+	Для Каждого Элемент Из Коллекция Цикл
+		Если Элемент < 0 Тогда
+			Продолжить;
+		ИначеЕсли Элемент > 100 Тогда
+			Прервать;
+		КонецЕсли;
+		Результат = Результат + Элемент;
+	КонецЦикла;
+
+	Возврат Результат;
+КонецФункции
+
+Функция Квадраты(Всего) Экспорт
+	Квадраты = Новый Массив;
+	Для Сч = 1 По Всего Цикл
+		Квадраты.Добавить(Сч * Сч);
+	КонецЦикла;
+
+	Результат = "";
+	Для Каждого Квадрат Из Квадраты Цикл
+		Результат = Результат + Строка(Квадрат) + ";";
+	КонецЦикла;
+
+	Возврат Результат;
+КонецФункции
+
+Функция ПерваяНечетная(Предел) Экспорт
+	Значения = Новый Массив;
+	Значения.Добавить(4);
+	Значения.Добавить(-1);
+	Значения.Добавить(9);
+	Значения.Добавить(7);
+
+	Найдено = 0;
+	Для Каждого Значение Из Значения Цикл
+		Если Значение < 0 Тогда
+			Продолжить;
+		КонецЕсли;
+		Если Значение % 2 = 1 И Значение <= Предел Тогда
+			Найдено = Значение;
+			Прервать;
+		КонецЕсли;
+	КонецЦикла;
+
+	Возврат Найдено;
+КонецФункции
+
+Функция Факториал(Число) Экспорт
+	Результат = 1;
+	Сч = 2;
+	Пока Сч <= Число Цикл
+		Результат = Результат * Сч;
+		Сч = Сч + 1;
+	КонецЦикла;
+
+	Возврат Результат;
+КонецФункции
+
+Функция Описание(Значение) Экспорт
+	Если Значение > 10 Тогда
+		Текст = "большое";
+	ИначеЕсли Значение > 0 Тогда
+		Текст = "маленькое";
+	Иначе
+		Текст = "не положительное";
+	КонецЕсли;
+
+	Для Сч = 1 По 3 Цикл
+		Текст = Текст + ", " + Строка(Сч * Значение);
+	КонецЦикла;
+
+	Сообщить(Текст);
+	Возврат ВРег(Лев(Текст, 5));
+КонецФункции
+
+Процедура Удвоить(Значение)
+	Значение = Значение * 2;
+КонецПроцедуры
+
+Функция Деление(а, б) Экспорт
+	Попытка
+		Результат = а / б;
+	Исключение
+		Сообщить("ошибка деления");
+		Результат = 0;
+	КонецПопытки;
+
+	Удвоить(Результат);
+	Возврат Результат;
+КонецФункции`
+
+func TestEquivalence(t *testing.T) {
+	calls := []struct {
+		method string
+		args   []interface{}
+	}{
+		{"Сумма", []interface{}{[]interface{}{1, -2, 3, 200, 4}}},
+		{"Квадраты", []interface{}{4}},
+		{"ПерваяНечетная", []interface{}{8}},
+		{"ПерваяНечетная", []interface{}{5}},
+		{"Факториал", []interface{}{6}},
+		{"Описание", []interface{}{42}},
+		{"Описание", []interface{}{-1}},
+		{"Деление", []interface{}{7, 2}},
+		{"Деление", []interface{}{7, 0}},
+	}
+
+	configs := map[string]Config{
+		"strings":    {HideString: true},
+		"ternary":    {RepExpByTernary: true},
+		"eval":       {RepExpByEval: true, Intensity: Intensity{Eval: Level{Probability: ptr(1.0)}}},
+		"goto":       {RepLoopByGoto: true, Intensity: Intensity{Goto: Level{Probability: ptr(1.0)}}},
+		"conditions": {ChangeConditions: true},
+		"garbage":    {AppendGarbage: true, Intensity: Intensity{Garbage: Level{Probability: ptr(1.0), MaxNesting: ptr(2)}}},
+		"callStack":  {CallStackHell: true},
+		"exceptions": {ExceptionFlow: true, Intensity: Intensity{Exceptions: Level{Probability: ptr(1.0)}}},
+		"rename":     {RenameMethods: true, RenameVariables: true},
+		"8.3.10":     {HideString: true, AppendGarbage: true, Target: Target{PlatformVersion: "8.3.10"}},
+	}
+	for _, p := range Presets() {
+		conf, err := p.Config()
+		assert.NoError(t, err)
+		configs[string(p)] = conf
+	}
+
+	for name, conf := range configs {
+		t.Run(name, func(t *testing.T) {
+			obCode, err := NewObfuscatory(context.Background(), conf).Obfuscate(equivalenceModule)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			for _, call := range calls {
+				original, err := interpreter.New(equivalenceModule)
+				if !assert.NoError(t, err) {
+					return
+				}
+				obfuscated, err := interpreter.New(obCode)
+				if !assert.NoError(t, err, obCode) {
+					return
+				}
+
+				want, err := original.Call(call.method, call.args...)
+				assert.NoError(t, err)
+				got, err := obfuscated.Call(call.method, call.args...)
+				if !assert.NoError(t, err, obCode) {
+					continue
+				}
+
+				assert.True(t, interpreter.Equal(want, got), "%s: %v != %v\n%s", call.method, want, got, obCode)
+				assert.Equal(t, original.Messages, obfuscated.Messages, call.method)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package obfuscator
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+)
+
+// PassExceptions ветвление через ВызватьИсключение внутри Попытка
+const PassExceptions = "exceptions"
+
+// методы, через которые код видит текущее исключение
+var errorInfoMethods = map[string]struct{}{
+	"описаниеошибки":     {},
+	"информацияобошибке": {},
+	"errordescription":   {},
+	"errorinfo":          {},
+}
+
+// ifToException вычисляет условие Если заранее, а результат передает через исключение:
+//
+//	Флаг = Булево(Условие);
+//	Попытка
+//		Если <истина> И Флаг Тогда ВызватьИсключение Декодер("..."); КонецЕсли;
+//		Флаг = <ложь>;
+//	Исключение
+//		Флаг = <истина>;
+//	КонецПопытки;
+//	Если Флаг Тогда
+//
+// Условие вычисляется вне Попытка, поэтому его ошибки не перехватываются
+func (c *Obfuscator) ifToException(currentFP *ast.FunctionOrProcedure, IF *ast.IfStatement) {
+	switch {
+	case observesErrors(currentFP):
+		c.report(SeverityWarning, DiagExceptionsSkipped, currentFP, "method reads current exception, conditions are not passed through exceptions")
+		return
+	case isElseIf(currentFP.Body, IF):
+		return
+	}
+
+	flag := ast.VarStatement{Name: c.randomString(c.intensity.Names.Variable)}
+	key := int32(c.random(10, 100))
+
+	stms := ast.Statements{
+		&ast.ExpStatement{
+			Operation: ast.OpEq,
+			Left:      flag,
+			Right:     ast.MethodStatement{Name: "Булево", Param: ast.ExprStatements{Statements: ast.Statements{IF.Expression}}},
+		},
+		&ast.TryStatement{
+			Body: ast.Statements{
+				&ast.IfStatement{
+					Expression: c.helperAppendConditions(flag, c.depth(c.intensity.Conditions)),
+					TrueBlock: ast.Statements{ast.ThrowStatement{
+						Param: c.createObfuscateStringStatement(currentFP.Directive, c.randomString(c.intensity.Names.Function), key),
+					}},
+				},
+				&ast.ExpStatement{Operation: ast.OpEq, Left: flag, Right: c.convStrExpToExpStatement(<-c.falseCondition)},
+			},
+			Catch: ast.Statements{
+				&ast.ExpStatement{Operation: ast.OpEq, Left: flag, Right: c.convStrExpToExpStatement(<-c.trueCondition)},
+			},
+		},
+	}
+
+	if insertBefore(&currentFP.Body, IF, stms) {
+		IF.Expression = flag
+		c.count(PassExceptions)
+	}
+}
+
+// observesErrors обращается ли метод к текущему исключению (ОписаниеОшибки(), ИнформацияОбОшибке(), ВызватьИсключение без параметров).
+// В таких методах новые Попытка изменят то, что увидит код в блоке Исключение
+func observesErrors(f *ast.FunctionOrProcedure) bool {
+	return findValue(reflect.ValueOf(f.Body), func(stm ast.Statement) bool {
+		switch v := stm.(type) {
+		case ast.MethodStatement:
+			_, ok := errorInfoMethods[strings.ToLower(v.Name)]
+			return ok
+		case ast.ThrowStatement:
+			return v.Param == nil
+		case *ast.ThrowStatement:
+			return v.Param == nil
+		}
+
+		return false
+	})
+}
+
+// isElseIf является ли условие веткой ИначеЕсли другого условия
+func isElseIf(body ast.Statements, IF *ast.IfStatement) bool {
+	return findValue(reflect.ValueOf(body), func(stm ast.Statement) bool {
+		if parent, ok := stm.(*ast.IfStatement); ok {
+			for _, item := range parent.IfElseBlock {
+				if elseIf, ok := item.(*ast.IfStatement); ok && elseIf == IF {
+					return true
+				}
+			}
+		}
+
+		return false
+	})
+}
+
+// insertBefore вставляет конструкции перед target, target ищется в том числе во вложенных блоках
+func insertBefore(body *ast.Statements, target ast.Statement, stms ast.Statements) bool {
+	for i, stm := range *body {
+		if sameNode(stm, target) {
+			*body = append(append(append(ast.Statements{}, (*body)[:i]...), stms...), (*body)[i:]...)
+			return true
+		}
+	}
+
+	for _, stm := range *body {
+		if eachBody(stm, func(nested *ast.Statements) bool { return insertBefore(nested, target, stms) }) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sameNode один и тот же ли это узел дерева, узлы-значения не сравниваются
+func sameNode(a, b ast.Statement) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	return va.Kind() == reflect.Ptr && vb.Kind() == reflect.Ptr && va.Type() == vb.Type() && va.Pointer() == vb.Pointer()
+}
+
+// jumpsOutOfTry есть ли Прервать/Продолжить цикла внутри Попытка. Платформа не позволяет переходить Перейти
+// из блока Попытка/Исключение наружу, поэтому такой цикл нельзя заменить на переходы
+func jumpsOutOfTry(body ast.Statements, inTry bool) bool {
+	for _, stm := range body {
+		switch v := stm.(type) {
+		case ast.ContinueStatement, *ast.ContinueStatement, ast.BreakStatement, *ast.BreakStatement:
+			if inTry {
+				return true
+			}
+		case ast.LoopStatement, *ast.LoopStatement:
+			// у вложенного цикла свои переходы
+		case ast.TryStatement:
+			if jumpsOutOfTry(v.Body, true) || jumpsOutOfTry(v.Catch, true) {
+				return true
+			}
+		case *ast.TryStatement:
+			if jumpsOutOfTry(v.Body, true) || jumpsOutOfTry(v.Catch, true) {
+				return true
+			}
+		default:
+			if eachBody(stm, func(nested *ast.Statements) bool { return jumpsOutOfTry(*nested, inTry) }) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,85 @@
+package obfuscator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJumpsOutOfTry(t *testing.T) {
+	body := ast.Statements{
+		&ast.TryStatement{Body: ast.Statements{&ast.IfStatement{TrueBlock: ast.Statements{ast.BreakStatement{}}}}},
+	}
+	assert.True(t, jumpsOutOfTry(body, false))
+
+	// Прервать относится к циклу внутри Попытка
+	body = ast.Statements{
+		&ast.TryStatement{Body: ast.Statements{&ast.LoopStatement{WhileExpr: true, Body: ast.Statements{ast.BreakStatement{}}}}},
+		ast.ContinueStatement{},
+	}
+	assert.False(t, jumpsOutOfTry(body, false))
+
+	IF := &ast.IfStatement{Expression: true}
+	elseIf := &ast.IfStatement{Expression: false}
+	IF.IfElseBlock = ast.Statements{elseIf}
+	fpBody := ast.Statements{&ast.LoopStatement{WhileExpr: true, Body: ast.Statements{IF}}}
+	assert.True(t, isElseIf(fpBody, elseIf))
+	assert.False(t, isElseIf(fpBody, IF))
+	assert.True(t, insertBefore(&fpBody, IF, ast.Statements{ast.BreakStatement{}}))
+	assert.Equal(t, ast.BreakStatement{}, fpBody[0].(*ast.LoopStatement).Body[0])
+}
+
+func TestExceptionFlow(t *testing.T) {
+	code := `Процедура Тест(Значение)
+	Если Значение > 10 Тогда
+		Сообщить("больше");
+	ИначеЕсли Значение = 0 Тогда
+		Сообщить("ноль");
+	КонецЕсли;
+	Пока Значение > 0 Цикл
+		Попытка
+			Значение = Значение - 1;
+			Если Значение = 5 Тогда
+				Прервать;
+			КонецЕсли;
+		Исключение
+			Продолжить;
+		КонецПопытки;
+	КонецЦикла;
+КонецПроцедуры`
+
+	obf := NewObfuscatory(context.Background(), Config{
+		ExceptionFlow: true,
+		RepLoopByGoto: true,
+		Intensity:     Intensity{Exceptions: Level{Probability: ptr(1.0)}},
+	})
+	obCode, err := obf.Obfuscate(code)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Contains(t, obCode, "Булево(Значение > 10)")
+	assert.Contains(t, obCode, "ВызватьИсключение")
+	// Прервать и Продолжить внутри Попытка, цикл нельзя заменить на Перейти
+	assert.Contains(t, obCode, "Пока Значение > 0 Цикл")
+	assert.Contains(t, obCode, "Прервать")
+
+	code = `Процедура Тест()
+	Попытка
+		Выполнить("Тест()");
+	Исключение
+		Если Истина Тогда
+			Сообщить(ОписаниеОшибки());
+		КонецЕсли;
+	КонецПопытки;
+КонецПроцедуры`
+
+	obf = NewObfuscatory(context.Background(), Config{ExceptionFlow: true, Intensity: Intensity{Exceptions: Level{Probability: ptr(1.0)}}})
+	obCode, err = obf.Obfuscate(code)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, strings.Count(obCode, "Попытка"))
+	}
+}
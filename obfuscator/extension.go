@@ -0,0 +1,60 @@
+package obfuscator
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Аннотации методов расширения ссылаются на метод расширяемой конфигурации по имени:
+//
+//	&Перед("ПриСозданииНаСервере"), &После(...), &Вместо(...) - тело метода наше и обфусцируется как обычно,
+//	&ИзменениеИКонтроль(...) - тело должно совпадать с исходным методом (кроме блоков #Вставка/#Удаление),
+//	поэтому такой метод выводится без изменений
+var (
+	extensionRe  = regexp.MustCompile(`(?i)^\s*&(?:перед|после|вместо|изменениеиконтроль|before|after|around|changeandvalidate)\s*\(`)
+	controlledRe = regexp.MustCompile(`(?i)^\s*&(?:изменениеиконтроль|changeandvalidate)\s*\(`)
+	identRe      = regexp.MustCompile(`[\p{L}_][\p{L}\p{N}_]*`)
+)
+
+// isControlled есть ли среди аннотаций &ИзменениеИКонтроль
+func isControlled(annotations []string) bool {
+	for _, a := range annotations {
+		if controlledRe.MatchString(a) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withAnnotations вставляет аннотации расширения перед объявлением метода в напечатанном тексте
+func withAnnotations(text, name string, annotations []string) string {
+	if len(annotations) == 0 {
+		return text
+	}
+
+	re := regexp.MustCompile(`(?i)(?:асинх\s+|async\s+)?(?:процедура|функция|procedure|function)\s+` + regexp.QuoteMeta(name) + `\s*\(`)
+	loc := re.FindStringIndex(text)
+	if loc == nil {
+		return strings.Join(annotations, "\n") + "\n" + text
+	}
+
+	return text[:loc[0]] + strings.Join(annotations, "\n") + "\n" + text[loc[0]:]
+}
+
+// controlledIdents идентификаторы, которые используются в методах с &ИзменениеИКонтроль.
+// Эти методы не меняются, поэтому вызываемые из них методы и переменные модуля нельзя переименовывать
+func (c *Obfuscator) controlledIdents() map[string]struct{} {
+	result := map[string]struct{}{}
+	if c.pre == nil {
+		return result
+	}
+
+	for _, item := range c.pre.controlled {
+		for _, ident := range identRe.FindAllString(item.text, -1) {
+			result[strings.ToLower(ident)] = struct{}{}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,72 @@
+package obfuscator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testControlledMethod = `&НаСервере
+&ИзменениеИКонтроль("ЗаполнитьТовары")
+Процедура Расш1_ЗаполнитьТовары()
+	Товары.Очистить();
+	#Вставка
+	Расш1_Проверить("добавлено");
+	#КонецВставки
+	#Удаление
+	Сообщить("удалено");
+	#КонецУдаления
+КонецПроцедуры`
+
+const testExtensionModule = `&НаСервере
+&Перед("ПриСозданииНаСервере")
+Процедура Расш1_ПриСозданииНаСервере(Отказ, СтандартнаяОбработка)
+	Сообщить("перед");
+КонецПроцедуры
+
+` + testControlledMethod + `
+
+&НаСервере
+Процедура Расш1_Проверить(Текст)
+	Сообщить(Текст);
+КонецПроцедуры
+
+&НаСервере
+Процедура Расш1_Служебная()
+	Сообщить("служебная");
+КонецПроцедуры`
+
+func TestParseExtension(t *testing.T) {
+	pre, err := parsePreprocessor(testExtensionModule)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.True(t, pre.extension)
+	assert.Equal(t, []string{`&Перед("ПриСозданииНаСервере")`}, pre.methods[0].annotations)
+	if assert.Len(t, pre.controlled, 1) {
+		assert.Equal(t, testControlledMethod, pre.controlled[0].text)
+	}
+
+	assert.NotContains(t, pre.source, "&Перед")
+	assert.NotContains(t, pre.source, "#Вставка")
+	assert.Equal(t, strings.Count(testExtensionModule, "\n"), strings.Count(pre.source, "\n"))
+
+	assert.Equal(t, "&НаСервере\n&После(\"А\")\nПроцедура Б()\nКонецПроцедуры", withAnnotations("&НаСервере\nПроцедура Б()\nКонецПроцедуры", "Б", []string{`&После("А")`}))
+}
+
+func TestObfuscateExtension(t *testing.T) {
+	obf := NewObfuscatory(context.Background(), Config{HideString: true, RenameMethods: true})
+	obCode, err := obf.Obfuscate(testExtensionModule)
+	if assert.NoError(t, err) {
+		assert.Contains(t, obCode, `&Перед("ПриСозданииНаСервере")`)
+		assert.Contains(t, obCode, testControlledMethod)
+		assert.NotContains(t, obCode, `"перед"`)
+		assert.NotContains(t, obCode, `"служебная"`)
+		assert.NotContains(t, obCode, "Расш1_Служебная")
+		// вызывается из метода с &ИзменениеИКонтроль
+		assert.Contains(t, obCode, "Процедура Расш1_Проверить(")
+	}
+}
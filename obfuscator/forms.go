@@ -0,0 +1,86 @@
+package obfuscator
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FormHandlersMode прежнее имя HandlersMode, оставлено для совместимости
+type FormHandlersMode = HandlersMode
+
+// Прежние имена режимов, оставлены для совместимости
+const (
+	FormHandlersPreserve = HandlersPreserve
+	FormHandlersRename   = HandlersRename
+)
+
+var formHandlerRe = regexp.MustCompile(`(<(?:Event|Action)\b[^>]*>)(\s*)([^<\s]+)(\s*)(</(?:Event|Action)>)`)
+
+// formXMLPath путь к Form.xml для модуля формы (.../Ext/Form/Module.bsl -> .../Ext/Form.xml).
+// Для остальных модулей возвращается пустая строка
+func formXMLPath(modulePath string) string {
+	modulePath = path.Clean(strings.ReplaceAll(modulePath, "\\", "/"))
+	dir, file := path.Split(modulePath)
+	dir = strings.TrimSuffix(dir, "/")
+
+	if !strings.EqualFold(file, "Module.bsl") || !strings.EqualFold(path.Base(dir), "Form") {
+		return ""
+	}
+
+	return path.Join(path.Dir(dir), "Form.xml")
+}
+
+// formHandlers имена методов, на которые ссылается форма: события формы и элементов (<Event>) и действия команд (<Action>)
+func formHandlers(data []byte) ([]string, error) {
+	var result []string
+	var stack []string
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "parse form error")
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name.Local)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) == 0 {
+				continue
+			}
+
+			if top := stack[len(stack)-1]; top == "Event" || top == "Action" {
+				if name := strings.TrimSpace(string(t)); name != "" {
+					result = append(result, name)
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// renameFormHandlers заменяет имена обработчиков в Form.xml, renames - старое имя (в нижнем регистре) -> новое
+func renameFormHandlers(data []byte, renames map[string]string) []byte {
+	return formHandlerRe.ReplaceAllFunc(data, func(match []byte) []byte {
+		parts := formHandlerRe.FindSubmatch(match)
+		newName, ok := renames[strings.ToLower(string(parts[3]))]
+		if !ok {
+			return match
+		}
+
+		return bytes.Join([][]byte{parts[1], parts[2], []byte(newName), parts[4], parts[5]}, nil)
+	})
+}
@@ -0,0 +1,104 @@
+package obfuscator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testFormXML = `<?xml version="1.0" encoding="UTF-8"?>
+<Form xmlns="http://v8.1c.ru/8.3/xcf/logform" version="2.16">
+	<Events>
+		<Event name="OnOpen">ПриОткрытии</Event>
+		<Event name="OnCreateAtServer">ПриСозданииНаСервере</Event>
+	</Events>
+	<ChildItems>
+		<InputField name="Каталог" id="1">
+			<Events>
+				<Event name="StartChoice">КаталогНачалоВыбора</Event>
+			</Events>
+		</InputField>
+	</ChildItems>
+	<Commands>
+		<Command name="Тест" id="2">
+			<Action>Тест</Action>
+		</Command>
+	</Commands>
+</Form>`
+
+const testFormModule = `&НаКлиенте
+Процедура ПриОткрытии(Отказ)
+	ЗаполнитьНаСервере();
+КонецПроцедуры
+
+&НаСервере
+Процедура ЗаполнитьНаСервере()
+КонецПроцедуры
+
+&НаКлиенте
+Процедура КаталогНачалоВыбора(Элемент, ДанныеВыбора, СтандартнаяОбработка)
+КонецПроцедуры
+
+&НаКлиенте
+Процедура Тест(Команда)
+КонецПроцедуры`
+
+func TestFormHandlers(t *testing.T) {
+	assert.Equal(t, "Catalogs/Товары/Forms/ФормаЭлемента/Ext/Form.xml", formXMLPath("Catalogs/Товары/Forms/ФормаЭлемента/Ext/Form/Module.bsl"))
+	assert.Equal(t, "CommonForms/Форма/Ext/Form.xml", formXMLPath(`CommonForms\Форма\Ext\Form\Module.bsl`))
+	assert.Empty(t, formXMLPath("CommonModules/Модуль/Ext/Module.bsl"))
+
+	names, err := formHandlers([]byte(testFormXML))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"ПриОткрытии", "ПриСозданииНаСервере", "КаталогНачалоВыбора", "Тест"}, names)
+	}
+
+	renamed := string(renameFormHandlers([]byte(testFormXML), map[string]string{"тест": "abc", "каталогначаловыбора": "def"}))
+	assert.Contains(t, renamed, "<Action>abc</Action>")
+	assert.Contains(t, renamed, `<Event name="StartChoice">def</Event>`)
+	assert.Contains(t, renamed, `<Event name="OnOpen">ПриОткрытии</Event>`)
+	assert.Contains(t, renamed, `<Command name="Тест" id="2">`)
+}
+
+func TestObfuscateDir(t *testing.T) {
+	for _, mode := range []HandlersMode{HandlersPreserve, HandlersRename} {
+		t.Run(string(mode), func(t *testing.T) {
+			src, dst := t.TempDir(), t.TempDir()
+			formDir := filepath.Join(src, "DataProcessors", "Обработка", "Forms", "Форма", "Ext")
+			assert.NoError(t, os.MkdirAll(filepath.Join(formDir, "Form"), os.ModePerm))
+			assert.NoError(t, os.WriteFile(filepath.Join(formDir, "Form.xml"), []byte(testFormXML), 0o644))
+			assert.NoError(t, os.WriteFile(filepath.Join(formDir, "Form", "Module.bsl"), append(utf8BOM, testFormModule...), 0o644))
+
+			err := ObfuscateDir(context.Background(), src, dst, BatchOptions{
+				ConfigFor: func(string) (Config, bool, error) {
+					return Config{RenameMethods: true}, true, nil
+				},
+				FormHandlers: mode,
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			dstForm := filepath.Join(dst, "DataProcessors", "Обработка", "Forms", "Форма", "Ext")
+			module, _ := os.ReadFile(filepath.Join(dstForm, "Form", "Module.bsl"))
+			form, _ := os.ReadFile(filepath.Join(dstForm, "Form.xml"))
+
+			assert.Equal(t, utf8BOM, module[:3])
+			assert.NotContains(t, string(module), "ЗаполнитьНаСервере")
+			assert.Contains(t, string(module), "ПриОткрытии")
+			assert.Contains(t, string(form), "ПриОткрытии")
+
+			if mode == HandlersPreserve {
+				assert.Contains(t, string(module), "КаталогНачалоВыбора")
+				assert.Equal(t, testFormXML, string(form))
+			} else {
+				assert.NotContains(t, string(module), "КаталогНачалоВыбора")
+				assert.NotContains(t, string(form), "КаталогНачалоВыбора")
+				assert.NotContains(t, string(form), "<Action>Тест</Action>")
+			}
+		})
+	}
+}
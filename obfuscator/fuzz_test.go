@@ -0,0 +1,222 @@
+package obfuscator
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+	"github.com/LazarenkoA/Obfuscator-1C/interpreter"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	regressionsDir = "testdata/regressions"
+	configPrefix   = "// config: "
+
+	// обфускация случайна, поэтому каждая проверка повторяется
+	fuzzAttempts = 3
+)
+
+// FuzzObfuscate обфусцирует сгенерированный метод случайным набором проходов и сравнивает выполнение исходного
+// и обфусцированного кода. Паника, неразбираемый результат или другой результат выполнения уменьшаются до
+// минимального метода с той же ошибкой и сохраняются в testdata/regressions, оттуда их проверяет TestFuzzRegressions
+//
+//	go test ./obfuscator -run XXX -fuzz FuzzObfuscate
+func FuzzObfuscate(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0xff, 0x03, 5, 3, 1, 2, 4, 6, 0, 7})
+	f.Add([]byte("obfuscator"))
+	f.Add([]byte{8, 0, 3, 4, 1, 5, 6, 2, 7, 3, 9, 1, 1, 0, 4})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		gen := newCodeGen(data)
+		conf := gen.config()
+		fp := gen.function()
+
+		code := printFunction(fp)
+		if _, err := runBSL(code); err != nil {
+			t.Fatalf("сгенерирован некорректный код: %v\n%s", err, code)
+		}
+
+		err := checkObfuscation(code, conf, fuzzAttempts)
+		if err == nil {
+			return
+		}
+
+		minimizeFunction(fp, func() bool {
+			candidate := printFunction(fp)
+			if _, err := runBSL(candidate); err != nil {
+				return false
+			}
+			return checkObfuscation(candidate, conf, fuzzAttempts) != nil
+		})
+
+		code = printFunction(fp)
+		path, saveErr := saveRegression(code, conf)
+		if saveErr != nil {
+			t.Log(saveErr)
+		}
+		t.Fatalf("%v\nсохранено в %s:\n%s", checkObfuscation(code, conf, fuzzAttempts), path, code)
+	})
+}
+
+func TestFuzzRegressions(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(regressionsDir, "*.bsl"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			code, conf, err := loadRegression(file)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.NoError(t, checkObfuscation(code, conf, 10))
+		})
+	}
+}
+
+// bslRun результат выполнения функции Тест()
+type bslRun struct {
+	result   interface{}
+	messages []string
+	err      error
+}
+
+// runBSL выполняет функцию Тест(), ошибка - код не разобран
+func runBSL(code string) (bslRun, error) {
+	i, err := interpreter.New(code)
+	if err != nil {
+		return bslRun{}, err
+	}
+
+	result, err := i.Call("Тест")
+	return bslRun{result: result, messages: i.Messages, err: err}, nil
+}
+
+// checkObfuscation обфусцирует код attempts раз и сравнивает выполнение с исходным
+func checkObfuscation(code string, conf Config, attempts int) error {
+	want, err := runBSL(code)
+	if err != nil {
+		return err
+	}
+
+	for n := 0; n < attempts; n++ {
+		if err := checkOnce(code, conf, want); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkOnce(code string, conf Config, want bslRun) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	obCode, err := NewObfuscatory(ctx, conf).Obfuscate(code)
+	if err != nil {
+		return errors.Wrap(err, "obfuscate error")
+	}
+
+	if err := ast.NewAST(obCode).Parse(); err != nil {
+		return errors.Wrapf(err, "обфусцированный код не разбирается\n%s", obCode)
+	}
+
+	got, err := runBSL(obCode)
+	if err != nil {
+		return errors.Wrapf(err, "обфусцированный код не разбирается интерпретатором\n%s", obCode)
+	}
+
+	switch {
+	case (want.err == nil) != (got.err == nil):
+		return errors.Errorf("ошибка выполнения: %v != %v\n%s", want.err, got.err, obCode)
+	case !interpreter.Equal(want.result, got.result):
+		return errors.Errorf("результат: %v != %v\n%s", want.result, got.result, obCode)
+	case strings.Join(want.messages, "\n") != strings.Join(got.messages, "\n"):
+		return errors.Errorf("сообщения: %q != %q\n%s", want.messages, got.messages, obCode)
+	}
+
+	return nil
+}
+
+// minimizeFunction удаляет операторы метода (в том числе во вложенных блоках), пока fails возвращает true
+func minimizeFunction(fp *ast.FunctionOrProcedure, fails func() bool) {
+	for changed := true; changed; {
+		changed = minimizeBlock(&fp.Body, fails)
+	}
+}
+
+func minimizeBlock(body *ast.Statements, fails func() bool) (changed bool) {
+	for i := 0; i < len(*body); {
+		saved := *body
+		*body = append(append(ast.Statements{}, saved[:i]...), saved[i+1:]...)
+		if fails() {
+			changed = true
+			continue
+		}
+
+		*body = saved
+		eachBody((*body)[i], func(nested *ast.Statements) bool {
+			changed = minimizeBlock(nested, fails) || changed
+			return false
+		})
+		i++
+	}
+
+	return changed
+}
+
+func printFunction(fp *ast.FunctionOrProcedure) string {
+	a := ast.NewAST("")
+	a.ModuleStatement = ast.ModuleStatement{Body: ast.Statements{fp}}
+	return a.Print(ast.PrintConf{Margin: 1})
+}
+
+// saveRegression сохраняет метод с настройками в testdata/regressions, имя файла - хеш кода
+func saveRegression(code string, conf Config) (string, error) {
+	header, err := json.Marshal(conf)
+	if err != nil {
+		return "", errors.Wrap(err, "marshal config error")
+	}
+
+	hash := sha1.Sum([]byte(code))
+	path := filepath.Join(regressionsDir, hex.EncodeToString(hash[:])[:12]+".bsl")
+	if err := os.MkdirAll(regressionsDir, os.ModePerm); err != nil {
+		return "", errors.Wrap(err, "create dir error")
+	}
+
+	return path, errors.Wrap(os.WriteFile(path, []byte(configPrefix+string(header)+"\n"+code), 0o644), "write file error")
+}
+
+// loadRegression читает метод и настройки, с которыми он сохранен
+func loadRegression(path string) (string, Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", Config{}, errors.Wrap(err, "read file error")
+	}
+
+	header, code, _ := strings.Cut(string(data), "\n")
+	var conf Config
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(header, configPrefix)), &conf); err != nil {
+		return "", Config{}, errors.Wrap(err, "unmarshal config error")
+	}
+
+	return code, conf, nil
+}
@@ -0,0 +1,365 @@
+package obfuscator
+
+import (
+	"strconv"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+)
+
+const (
+	genMaxStatements = 6
+	genMaxDepth      = 3
+)
+
+// строки без "|" и кавычек: "|" обфускатор заменяет на пробел (многострочные литералы)
+var genWords = []string{"", "а", "строка", "Привет мир", "test", "123", " пробелы "}
+
+// codeGen строит метод из случайных, но корректных конструкций ast. Источник случайности - данные фаззера:
+// одни и те же данные дают один и тот же код, когда данные заканчиваются, выбирается простейший вариант.
+// Переменные типизированы (числа и строки), циклы всегда завершаются, деления нет
+type codeGen struct {
+	data []byte
+	pos  int
+
+	numbers  []string // числовые переменные, которым можно присваивать
+	strs     []string // строковые переменные
+	counters []string // счетчики циклов, только для чтения
+	vars     int
+	loops    int
+	depth    int
+}
+
+func newCodeGen(data []byte) *codeGen {
+	return &codeGen{data: data}
+}
+
+func (g *codeGen) next(n int) int {
+	if n <= 0 || g.pos >= len(g.data) {
+		return 0
+	}
+
+	g.pos++
+	return int(g.data[g.pos-1]) % n
+}
+
+// config случайный набор проходов
+func (g *codeGen) config() Config {
+	flags := g.next(1<<8) | g.next(1<<8)<<8
+	bit := func(i int) bool { return flags&(1<<i) != 0 }
+
+	return Config{
+		HideString:       bit(0),
+		RepExpByTernary:  bit(1),
+		RepExpByEval:     bit(2),
+		RepLoopByGoto:    bit(3),
+		ChangeConditions: bit(4),
+		AppendGarbage:    bit(5),
+		CallStackHell:    bit(6),
+		ExceptionFlow:    bit(7),
+		RenameMethods:    bit(8),
+		RenameVariables:  bit(9),
+		Intensity: Intensity{
+			Eval:       Level{Probability: ptr(1.0)},
+			Goto:       Level{Probability: ptr(1.0)},
+			Exceptions: Level{Probability: ptr(1.0)},
+		},
+	}
+}
+
+// function экспортная функция Тест() без параметров
+func (g *codeGen) function() *ast.FunctionOrProcedure {
+	f := &ast.FunctionOrProcedure{Type: ast.PFTypeFunction, Name: "Тест", Export: true}
+	number, str := g.number(), g.str()
+	f.Body = ast.Statements{
+		g.assign(g.newVar(&g.numbers), number),
+		g.assign(g.newVar(&g.strs), str),
+	}
+	f.Body = append(f.Body, g.block(false)...)
+
+	switch g.next(3) {
+	case 0:
+		f.Body = append(f.Body, &ast.ReturnStatement{Param: ast.VarStatement{Name: g.numbers[0]}})
+	case 1:
+		f.Body = append(f.Body, &ast.ReturnStatement{Param: g.number()})
+	default:
+		f.Body = append(f.Body, &ast.ReturnStatement{Param: g.str()})
+	}
+
+	return f
+}
+
+// block операторы блока. Переменные, объявленные во вложенном блоке, после него не используются
+func (g *codeGen) block(nested bool) ast.Statements {
+	numbers, strs := len(g.numbers), len(g.strs)
+	if nested {
+		defer func() { g.numbers, g.strs = g.numbers[:numbers], g.strs[:strs] }()
+	}
+
+	g.depth++
+	defer func() { g.depth-- }()
+
+	var body ast.Statements
+	for i := g.next(genMaxStatements) + 1; i > 0; i-- {
+		body = append(body, g.statement()...)
+	}
+
+	return body
+}
+
+func (g *codeGen) statement() ast.Statements {
+	kinds := 3
+	if g.depth < genMaxDepth {
+		kinds = 8
+	}
+
+	switch g.next(kinds) {
+	case 0:
+		value := g.number()
+		return ast.Statements{g.assign(g.numberVar(), value)}
+	case 1:
+		value := g.str()
+		return ast.Statements{g.assign(g.strVar(), value)}
+	case 2:
+		return ast.Statements{g.message()}
+	case 3:
+		return ast.Statements{g.ifStatement()}
+	case 4:
+		return g.whileLoop()
+	case 5:
+		return ast.Statements{g.forLoop()}
+	case 6:
+		return ast.Statements{g.forEachLoop()}
+	default:
+		return ast.Statements{g.try()}
+	}
+}
+
+func (g *codeGen) ifStatement() *ast.IfStatement {
+	IF := &ast.IfStatement{Expression: g.boolean(), TrueBlock: g.loopBlock()}
+	for i := g.next(3); i > 0; i-- {
+		IF.IfElseBlock = append(IF.IfElseBlock, &ast.IfStatement{Expression: g.boolean(), TrueBlock: g.loopBlock()})
+	}
+	if g.next(2) == 1 {
+		IF.ElseBlock = g.loopBlock()
+	}
+
+	return IF
+}
+
+// loopBlock блок, внутри цикла в нем может быть Прервать или Продолжить
+func (g *codeGen) loopBlock() ast.Statements {
+	body := g.block(true)
+	if g.loops > 0 {
+		switch g.next(4) {
+		case 1:
+			body = append(body, ast.BreakStatement{})
+		case 2:
+			body = append(body, ast.ContinueStatement{})
+		}
+	}
+
+	return body
+}
+
+// whileLoop Пока со счетчиком, счетчик увеличивается первым оператором, чтобы Продолжить его не пропускало.
+// Условие - сравнение, булева переменная или вызов функции
+func (g *codeGen) whileLoop() ast.Statements {
+	counter, limit := g.newVar(nil), float64(g.next(5))
+	// обфускация меняет выражения на месте, поэтому каждое условие - отдельное выражение
+	less := func() ast.Statement {
+		return &ast.ExpStatement{Operation: ast.OpLt, Left: ast.VarStatement{Name: counter}, Right: limit}
+	}
+	loop := &ast.LoopStatement{
+		WhileExpr: less(),
+		Body:      ast.Statements{increment(ast.VarStatement{Name: counter})},
+	}
+	result := ast.Statements{g.assign(counter, float64(0))}
+
+	switch g.next(3) {
+	case 1:
+		// флаг пересчитывается сразу после счетчика
+		flag := g.newVar(nil)
+		loop.WhileExpr = ast.VarStatement{Name: flag}
+		loop.Body = append(loop.Body, g.assign(flag, less()))
+		result = append(result, g.assign(flag, less()))
+	case 2:
+		left := &ast.ExpStatement{Operation: ast.OpMinus, Left: limit, Right: ast.VarStatement{Name: counter}}
+		loop.WhileExpr = call("Булево", call("Макс", left, float64(0)))
+	}
+	loop.Body = append(loop.Body, g.loopBody(counter)...)
+
+	return append(result, loop)
+}
+
+func (g *codeGen) forLoop() *ast.LoopStatement {
+	counter := g.newVar(nil)
+	from := g.next(3)
+
+	return &ast.LoopStatement{
+		For:  g.assign(counter, float64(from)),
+		To:   float64(from + g.next(5)),
+		Body: g.loopBody(counter),
+	}
+}
+
+// forEachLoop обход массива из Неопределено, элемент не используется
+func (g *codeGen) forEachLoop() *ast.LoopStatement {
+	return &ast.LoopStatement{
+		For:  ast.VarStatement{Name: g.newVar(nil)},
+		In:   ast.NewObjectStatement{Constructor: "Массив", Param: ast.ExprStatements{Statements: ast.Statements{float64(g.next(4))}}},
+		Body: g.loopBody(""),
+	}
+}
+
+func (g *codeGen) loopBody(counter string) ast.Statements {
+	if counter != "" {
+		g.counters = append(g.counters, counter)
+		defer func() { g.counters = g.counters[:len(g.counters)-1] }()
+	}
+
+	g.loops++
+	defer func() { g.loops-- }()
+
+	return g.loopBlock()
+}
+
+func (g *codeGen) try() *ast.TryStatement {
+	try := &ast.TryStatement{Body: g.loopBlock(), Catch: ast.Statements{g.message()}}
+	if g.next(2) == 1 {
+		try.Body = append(try.Body, ast.ThrowStatement{Param: g.str()})
+	}
+	if g.next(2) == 1 {
+		try.Catch = append(try.Catch, g.loopBlock()...)
+	}
+
+	return try
+}
+
+func (g *codeGen) message() ast.MethodStatement {
+	param := g.str()
+	if g.next(2) == 1 {
+		param = g.number()
+	}
+
+	return call("Сообщить", param)
+}
+
+func (g *codeGen) number() ast.Statement {
+	g.depth++
+	defer func() { g.depth-- }()
+
+	kinds := 2
+	if g.depth < genMaxDepth*2 {
+		kinds = 7
+	}
+
+	switch g.next(kinds) {
+	case 0:
+		if g.next(4) == 3 {
+			return float64(g.next(100)) + 0.5
+		}
+		return float64(g.next(1000))
+	case 1:
+		if vars := append(append([]string{}, g.numbers...), g.counters...); len(vars) > 0 {
+			return ast.VarStatement{Name: vars[g.next(len(vars))]}
+		}
+		return float64(g.next(10))
+	case 2:
+		ops := []ast.OperationType{ast.OpPlus, ast.OpMinus, ast.OpMul}
+		return &ast.ExpStatement{Operation: ops[g.next(len(ops))], Left: g.number(), Right: g.number()}
+	case 3:
+		return &ast.ExpStatement{Operation: ast.OpMod, Left: g.number(), Right: float64(g.next(9) + 1)}
+	case 4:
+		return ast.TernaryStatement{Expression: g.boolean(), TrueBlock: g.number(), ElseBlock: g.number()}
+	case 5:
+		return call("СтрДлина", g.str())
+	default:
+		return call("Макс", g.number(), g.number())
+	}
+}
+
+func (g *codeGen) str() ast.Statement {
+	g.depth++
+	defer func() { g.depth-- }()
+
+	kinds := 2
+	if g.depth < genMaxDepth*2 {
+		kinds = 6
+	}
+
+	switch g.next(kinds) {
+	case 0:
+		return genWords[g.next(len(genWords))]
+	case 1:
+		if len(g.strs) > 0 {
+			return ast.VarStatement{Name: g.strs[g.next(len(g.strs))]}
+		}
+		return genWords[g.next(len(genWords))]
+	case 2:
+		return &ast.ExpStatement{Operation: ast.OpPlus, Left: g.str(), Right: g.str()}
+	case 3:
+		return call("Строка", g.number())
+	case 4:
+		return call("Лев", g.str(), float64(g.next(5)))
+	default:
+		return ast.TernaryStatement{Expression: g.boolean(), TrueBlock: g.str(), ElseBlock: g.str()}
+	}
+}
+
+func (g *codeGen) boolean() ast.Statement {
+	g.depth++
+	defer func() { g.depth-- }()
+
+	kinds := 2
+	if g.depth < genMaxDepth*2 {
+		kinds = 3
+	}
+
+	switch g.next(kinds) {
+	case 0:
+		ops := []ast.OperationType{ast.OpGt, ast.OpLt, ast.OpEq, ast.OpNe, ast.OpGe, ast.OpLe}
+		return &ast.ExpStatement{Operation: ops[g.next(len(ops))], Left: g.number(), Right: g.number()}
+	case 1:
+		ops := []ast.OperationType{ast.OpEq, ast.OpNe}
+		return &ast.ExpStatement{Operation: ops[g.next(len(ops))], Left: g.str(), Right: g.str()}
+	default:
+		ops := []ast.OperationType{ast.OpAnd, ast.OpOr}
+		return &ast.ExpStatement{Operation: ops[g.next(len(ops))], Left: g.boolean(), Right: g.boolean()}
+	}
+}
+
+// numberVar новая или существующая числовая переменная
+func (g *codeGen) numberVar() string {
+	if len(g.numbers) == 0 || g.next(3) == 0 {
+		return g.newVar(&g.numbers)
+	}
+
+	return g.numbers[g.next(len(g.numbers))]
+}
+
+func (g *codeGen) strVar() string {
+	if len(g.strs) == 0 || g.next(3) == 0 {
+		return g.newVar(&g.strs)
+	}
+
+	return g.strs[g.next(len(g.strs))]
+}
+
+// newVar новое имя переменной. Вызывается после вычисления присваиваемого значения, чтобы оно не ссылалось на саму переменную
+func (g *codeGen) newVar(list *[]string) string {
+	g.vars++
+	name := "Перем" + strconv.Itoa(g.vars)
+	if list != nil {
+		*list = append(*list, name)
+	}
+
+	return name
+}
+
+func (g *codeGen) assign(name string, value ast.Statement) *ast.ExpStatement {
+	return &ast.ExpStatement{Operation: ast.OpEq, Left: ast.VarStatement{Name: name}, Right: value}
+}
+
+func call(name string, params ...ast.Statement) ast.MethodStatement {
+	return ast.MethodStatement{Name: name, Param: ast.ExprStatements{Statements: params}}
+}
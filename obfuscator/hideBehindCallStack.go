@@ -1,8 +1,9 @@
 package obfuscator
 
 import (
-	"github.com/LazarenkoA/1c-language-parser/ast"
 	"time"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
 )
 
 func (c *Obfuscator) hideBehindCallStack(directive string, val ast.ExprStatements, deep int) {
@@ -10,12 +11,18 @@ func (c *Obfuscator) hideBehindCallStack(directive string, val ast.ExprStatement
 		return
 	}
 
+	directive = c.directive(directive)
 	switch val.Statements[0].(type) {
 	case string, int, int32, int64, float32, float64, time.Time, bool:
+		if !c.canGenerate(deep + 1) {
+			return
+		}
+
 		funcName := c.createFakeFunc(directive, val.Statements[0])
 		c.wrapFunc(directive, funcName, deep)
 
 		val.Statements[0] = ast.MethodStatement{Name: funcName}
+		c.count(PassCallStack)
 	}
 }
 
@@ -29,7 +36,7 @@ func (c *Obfuscator) wrapFunc(directive string, callFuncName string, deep int) {
 }
 
 func (c *Obfuscator) createFakeFunc(directive string, value ast.Statement) string {
-	funcName := c.randomString(30)
+	funcName := c.randomString(c.intensity.Names.Function)
 
 	f := &ast.FunctionOrProcedure{
 		Type:      ast.PFTypeFunction,
@@ -39,27 +46,28 @@ func (c *Obfuscator) createFakeFunc(directive string, value ast.Statement) strin
 		Directive: directive,
 	}
 
-	if random(0, 2) == 1 {
+	if c.chance(c.intensity.Garbage.probability()) {
 		c.appendGarbage(&f.Body)
 	}
-	if random(0, 2) == 1 {
+	if c.chance(c.intensity.Garbage.probability()) {
 		c.appendGarbage(&f.Body)
 	}
-	if random(0, 2) == 1 {
+	if c.chance(c.intensity.Garbage.probability()) {
 		c.appendGarbage(&f.Body)
 	}
 
 	f.Body = append(f.Body, &ast.ReturnStatement{Param: value})
 
-	if random(0, 2) == 1 {
+	if c.chance(c.intensity.Garbage.probability()) {
 		c.appendGarbage(&f.Body)
 	}
-	if random(0, 2) == 1 {
+	if c.chance(c.intensity.Garbage.probability()) {
 		c.appendGarbage(&f.Body)
 	}
 
 	c.replaceAllLoopToGoto(&f.Body)
-	c.a.ModuleStatement.Body = append(c.a.ModuleStatement.Body, f)
+	c.addFunction(f)
+	c.owners[f] = c.current
 
 	return funcName
 }
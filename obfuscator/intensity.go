@@ -0,0 +1,190 @@
+package obfuscator
+
+// Level параметры интенсивности одного преобразования.
+// Незаданные значения (nil и нулевые глубины) заменяются значениями по умолчанию из DefaultIntensity
+type Level struct {
+	// Probability вероятность применения преобразования в каждом подходящем месте, от 0 до 1. 0 - преобразование не применяется
+	Probability *float64 `yaml:"probability,omitempty" json:"probability,omitempty"`
+
+	// MinDepth, MaxDepth глубина преобразования [MinDepth, MaxDepth)
+	MinDepth int `yaml:"minDepth,omitempty" json:"minDepth,omitempty"`
+	MaxDepth int `yaml:"maxDepth,omitempty" json:"maxDepth,omitempty"`
+
+	// MaxNesting максимальная вложенность сгенерированных конструкций, 0 - без вложенных конструкций
+	MaxNesting *int `yaml:"maxNesting,omitempty" json:"maxNesting,omitempty"`
+}
+
+// NameLength длины генерируемых имен
+type NameLength struct {
+	Label    int `yaml:"label,omitempty" json:"label,omitempty"`
+	Variable int `yaml:"variable,omitempty" json:"variable,omitempty"`
+	Function int `yaml:"function,omitempty" json:"function,omitempty"`
+}
+
+// Intensity параметры интенсивности преобразований, позволяют выбрать баланс между защитой и размером/скоростью кода
+type Intensity struct {
+	// Ternary глубина тернарных операторов, за которыми прячутся значения
+	Ternary Level `yaml:"ternary,omitempty" json:"ternary,omitempty"`
+
+	// Conditions MinDepth/MaxDepth - сколько истинных условий добавляется к условию Если,
+	// MaxNesting - сколько ложных веток ИначеЕсли может быть добавлено
+	Conditions Level `yaml:"conditions,omitempty" json:"conditions,omitempty"`
+
+	// Garbage Probability - вероятность добавить каждую мусорную конструкцию,
+	// MaxNesting - вложенность мусорных Если и Пока
+	Garbage Level `yaml:"garbage,omitempty" json:"garbage,omitempty"`
+
+	// CallStack MinDepth/MaxDepth - длина цепочки фейковых функций
+	CallStack Level `yaml:"callStack,omitempty" json:"callStack,omitempty"`
+
+	// Eval Probability - вероятность спрятать выражение в Выполнить()/Вычислить()
+	Eval Level `yaml:"eval,omitempty" json:"eval,omitempty"`
+
+	// Goto Probability - вероятность заменить цикл на Перейти
+	Goto Level `yaml:"goto,omitempty" json:"goto,omitempty"`
+
+	// Exceptions Probability - вероятность передать результат условия через исключение
+	Exceptions Level `yaml:"exceptions,omitempty" json:"exceptions,omitempty"`
+
+	// Predicates MinDepth/MaxDepth - количество операндов в каждой части фиктивного условия
+	Predicates Level `yaml:"predicates,omitempty" json:"predicates,omitempty"`
+
+	// Names длины генерируемых имен
+	Names NameLength `yaml:"names,omitempty" json:"names,omitempty"`
+}
+
+// DefaultIntensity значения интенсивности по умолчанию
+func DefaultIntensity() Intensity {
+	return Intensity{
+		Ternary:    Level{Probability: ptr(1.0), MinDepth: 2, MaxDepth: 4},
+		Conditions: Level{Probability: ptr(1.0), MinDepth: 3, MaxDepth: 4, MaxNesting: ptr(5)},
+		Garbage:    Level{Probability: ptr(0.5), MaxNesting: ptr(3)},
+		CallStack:  Level{Probability: ptr(1.0), MinDepth: 3, MaxDepth: 7},
+		Eval:       Level{Probability: ptr(1.0)},
+		Goto:       Level{Probability: ptr(1.0)},
+		Exceptions: Level{Probability: ptr(0.3)},
+		Predicates: Level{Probability: ptr(1.0), MinDepth: 2, MaxDepth: 7},
+		Names:      NameLength{Label: 5, Variable: 10, Function: 30},
+	}
+}
+
+// withDefaults заполняет незаданные параметры значениями по умолчанию
+func (i Intensity) withDefaults() Intensity {
+	def := DefaultIntensity()
+
+	i.Ternary = i.Ternary.withDefaults(def.Ternary)
+	i.Conditions = i.Conditions.withDefaults(def.Conditions)
+	i.Garbage = i.Garbage.withDefaults(def.Garbage)
+	i.CallStack = i.CallStack.withDefaults(def.CallStack)
+	i.Eval = i.Eval.withDefaults(def.Eval)
+	i.Goto = i.Goto.withDefaults(def.Goto)
+	i.Exceptions = i.Exceptions.withDefaults(def.Exceptions)
+	i.Predicates = i.Predicates.withDefaults(def.Predicates)
+
+	if i.Names.Label <= 0 {
+		i.Names.Label = def.Names.Label
+	}
+	if i.Names.Variable <= 0 {
+		i.Names.Variable = def.Names.Variable
+	}
+	if i.Names.Function <= 0 {
+		i.Names.Function = def.Names.Function
+	}
+
+	return i
+}
+
+func (l Level) withDefaults(def Level) Level {
+	if l.Probability == nil {
+		l.Probability = def.Probability
+	}
+	if l.MinDepth <= 0 {
+		l.MinDepth = def.MinDepth
+	}
+	if l.MaxDepth <= 0 {
+		l.MaxDepth = def.MaxDepth
+	}
+	if l.MaxDepth < l.MinDepth {
+		l.MaxDepth = l.MinDepth
+	}
+	if l.MaxNesting == nil {
+		l.MaxNesting = def.MaxNesting
+	}
+
+	return l
+}
+
+func (l Level) probability() float64 {
+	if l.Probability == nil {
+		return 0
+	}
+
+	return *l.Probability
+}
+
+func (l Level) nesting() int {
+	if l.MaxNesting == nil {
+		return 0
+	}
+
+	return *l.MaxNesting
+}
+
+// depth случайная глубина уровня из [MinDepth, MaxDepth)
+func (c *Obfuscator) depth(l Level) int {
+	if l.MaxDepth <= l.MinDepth {
+		return l.MinDepth
+	}
+
+	return int(c.random(l.MinDepth, l.MaxDepth))
+}
+
+// chance true с вероятностью p
+func (c *Obfuscator) chance(p float64) bool {
+	if p >= 1 {
+		return true
+	}
+
+	return float64(c.random(0, 10000)) < p*10000
+}
+
+// merge переопределяет параметры заданными параметрами over
+func (i Intensity) merge(over Intensity) Intensity {
+	i.Ternary = i.Ternary.merge(over.Ternary)
+	i.Conditions = i.Conditions.merge(over.Conditions)
+	i.Garbage = i.Garbage.merge(over.Garbage)
+	i.CallStack = i.CallStack.merge(over.CallStack)
+	i.Eval = i.Eval.merge(over.Eval)
+	i.Goto = i.Goto.merge(over.Goto)
+	i.Exceptions = i.Exceptions.merge(over.Exceptions)
+	i.Predicates = i.Predicates.merge(over.Predicates)
+
+	if over.Names.Label > 0 {
+		i.Names.Label = over.Names.Label
+	}
+	if over.Names.Variable > 0 {
+		i.Names.Variable = over.Names.Variable
+	}
+	if over.Names.Function > 0 {
+		i.Names.Function = over.Names.Function
+	}
+
+	return i
+}
+
+func (l Level) merge(over Level) Level {
+	if over.Probability != nil {
+		l.Probability = over.Probability
+	}
+	if over.MinDepth > 0 {
+		l.MinDepth = over.MinDepth
+	}
+	if over.MaxDepth > 0 {
+		l.MaxDepth = over.MaxDepth
+	}
+	if over.MaxNesting != nil {
+		l.MaxNesting = over.MaxNesting
+	}
+
+	return l
+}
@@ -0,0 +1,100 @@
+package obfuscator
+
+import (
+	_ "embed"
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// ModuleKind тип модуля
+type ModuleKind string
+
+const (
+	ModuleUnknown            ModuleKind = ""
+	ModuleObject             ModuleKind = "object"
+	ModuleRecordSet          ModuleKind = "recordSet"
+	ModuleValueManager       ModuleKind = "valueManager"
+	ModuleManager            ModuleKind = "manager"
+	ModuleForm               ModuleKind = "form"
+	ModuleCommand            ModuleKind = "command"
+	ModuleSession            ModuleKind = "session"
+	ModuleApplication        ModuleKind = "application"
+	ModuleExternalConnection ModuleKind = "externalConnection"
+	ModuleCommon             ModuleKind = "common"
+)
+
+//go:embed data/handlers.json
+var handlersData []byte
+
+// knowledgeBase имена, которые вызывает платформа и которые нельзя переименовывать
+type knowledgeBase struct {
+	Version  string                  `json:"version"`
+	Platform string                  `json:"platform"`
+	Modules  map[ModuleKind][]string `json:"modules"`
+
+	mx    sync.RWMutex
+	names map[ModuleKind]map[string]struct{}
+}
+
+var handlers = loadKnowledgeBase()
+
+func loadKnowledgeBase() *knowledgeBase {
+	kb := &knowledgeBase{names: map[ModuleKind]map[string]struct{}{}}
+	if err := json.Unmarshal(handlersData, kb); err != nil {
+		panic("handlers.json: " + err.Error())
+	}
+
+	for kind, names := range kb.Modules {
+		kb.add(kind, names...)
+	}
+
+	return kb
+}
+
+func (kb *knowledgeBase) add(kind ModuleKind, names ...string) {
+	kb.mx.Lock()
+	defer kb.mx.Unlock()
+
+	if kb.names[kind] == nil {
+		kb.names[kind] = map[string]struct{}{}
+	}
+	for _, name := range names {
+		kb.names[kind][strings.ToLower(name)] = struct{}{}
+	}
+}
+
+// has для неизвестного типа модуля проверяются все типы
+func (kb *knowledgeBase) has(kind ModuleKind, name string) bool {
+	kb.mx.RLock()
+	defer kb.mx.RUnlock()
+
+	name = strings.ToLower(name)
+	if kind != ModuleUnknown {
+		_, ok := kb.names[kind][name]
+		return ok
+	}
+
+	for _, names := range kb.names {
+		if _, ok := names[name]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// KnowledgeBaseVersion версия встроенной таблицы обработчиков событий и версия платформы, по которой она составлена
+func KnowledgeBaseVersion() (version, platform string) {
+	return handlers.Version, handlers.Platform
+}
+
+// RegisterPreservedNames добавляет имена, которые нельзя переименовывать в модулях указанного типа
+func RegisterPreservedNames(kind ModuleKind, names ...string) {
+	handlers.add(kind, names...)
+}
+
+// IsPreservedName является ли имя обработчиком события платформы для модуля указанного типа
+func IsPreservedName(kind ModuleKind, name string) bool {
+	return handlers.has(kind, name)
+}
@@ -0,0 +1,320 @@
+package obfuscator
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+)
+
+// loopToGoto заменяет цикл на переходы Перейти. Прервать и Продолжить внутри цикла (но не во вложенных циклах)
+// заменяются на переход к концу цикла и к следующей итерации
+func (c *Obfuscator) loopToGoto(loop *ast.LoopStatement) ast.Statements {
+	if jumpsOutOfTry(loop.Body, false) {
+		c.report(SeverityWarning, DiagGotoSkipped, c.current, "loop is not replaced with Перейти: Прервать or Продолжить inside Попытка")
+		return ast.Statements{loop}
+	}
+
+	start, next, end := c.newLabel(), c.newLabel(), c.newLabel()
+
+	switch {
+	case loop.WhileExpr != nil:
+		// цикл Пока
+		replaceJumps(loop.Body, start, end)
+
+		newBody := ast.Statements{
+			start,
+			&ast.IfStatement{
+				Expression: c.invertExp(loop.WhileExpr),
+				TrueBlock:  ast.Statements{ast.GoToStatement{Label: end}},
+			},
+		}
+
+		c.gotoLoop(start, end)
+		return append(append(newBody, loop.Body...), ast.GoToStatement{Label: start}, end)
+	case loop.In != nil:
+		// цикл Для Каждого
+		return c.forEachToGoto(loop, start, next, end)
+	case loop.To != nil:
+		// цикл Для а = 0 По n
+		exp, ok := loop.For.(*ast.ExpStatement)
+		if !ok {
+			c.report(SeverityWarning, DiagGotoSkipped, c.current, "unsupported Для loop is not replaced with Перейти")
+			return ast.Statements{loop}
+		}
+
+		// граница цикла вычисляется один раз, до первой итерации
+		newBody := ast.Statements{exp}
+		to := loop.To
+		if _, ok := to.(float64); !ok {
+			to = ast.VarStatement{Name: c.randomString(c.intensity.Names.Variable)}
+			newBody = append(newBody, &ast.ExpStatement{Operation: ast.OpEq, Left: to, Right: loop.To})
+		}
+
+		replaceJumps(loop.Body, next, end)
+
+		newBody = append(newBody, start, &ast.IfStatement{
+			Expression: &ast.ExpStatement{
+				Operation: ast.OpGt,
+				Left:      exp.Left,
+				Right:     to,
+			},
+			TrueBlock: ast.Statements{ast.GoToStatement{Label: end}},
+		})
+
+		c.gotoLoop(start, end)
+		return append(append(newBody, loop.Body...), next, increment(exp.Left), ast.GoToStatement{Label: start}, end)
+	}
+
+	return ast.Statements{loop}
+}
+
+// gotoLoop учитывает цикл, замененный на Перейти. Код между метками остается кодом цикла для режима Config.Performance
+func (c *Obfuscator) gotoLoop(start, end *ast.GoToLabelStatement) {
+	if c.loopLabels == nil {
+		c.loopLabels = map[*ast.GoToLabelStatement]*ast.GoToLabelStatement{}
+	}
+
+	c.loopLabels[start] = end
+	c.count(PassGoto)
+}
+
+// forEachToGoto заменяет Для Каждого на обход по индексу до Количество(). Доступ по индексу есть не у всех коллекций
+// (Структура, Соответствие), поэтому заменяются только циклы по коллекциям, тип которых виден в методе
+func (c *Obfuscator) forEachToGoto(loop *ast.LoopStatement, start, next, end *ast.GoToLabelStatement) ast.Statements {
+	item := c.printInline(loop.For)
+	collection := c.printInline(loop.In)
+	if item == "" || collection == "" {
+		c.report(SeverityWarning, DiagGotoSkipped, c.current, "Для Каждого loop is not replaced with Перейти: can't print loop header")
+		return ast.Statements{loop}
+	}
+	if !c.indexed(loop.In) {
+		c.report(SeverityWarning, DiagGotoSkipped, c.current, "Для Каждого loop is not replaced with Перейти: collection %q may not support index access", collection)
+		return ast.Statements{loop}
+	}
+
+	// коллекция вычисляется один раз, как в Для Каждого
+	source := c.randomString(c.intensity.Names.Variable)
+	index := ast.VarStatement{Name: c.randomString(c.intensity.Names.Variable)}
+	count := ast.VarStatement{Name: c.randomString(c.intensity.Names.Variable)}
+
+	prologue, ok := c.parseStatements(source + " = " + collection + ";\n" + count.Name + " = " + source + ".Количество();")
+	if !ok {
+		c.report(SeverityWarning, DiagGotoSkipped, c.current, "Для Каждого loop is not replaced with Перейти: collection %q does not parse", collection)
+		return ast.Statements{loop}
+	}
+
+	current, ok := c.parseStatements(item + " = " + source + "[" + index.Name + "];")
+	if !ok {
+		c.report(SeverityWarning, DiagGotoSkipped, c.current, "Для Каждого loop is not replaced with Перейти: item %q does not parse", item)
+		return ast.Statements{loop}
+	}
+
+	replaceJumps(loop.Body, next, end)
+
+	newBody := append(prologue,
+		&ast.ExpStatement{Operation: ast.OpEq, Left: index, Right: float64(0)},
+		start,
+		&ast.IfStatement{
+			Expression: &ast.ExpStatement{Operation: ast.OpGe, Left: index, Right: count},
+			TrueBlock:  ast.Statements{ast.GoToStatement{Label: end}},
+		})
+	newBody = append(append(newBody, current...), loop.Body...)
+
+	c.gotoLoop(start, end)
+	return append(newBody, next, increment(index), ast.GoToStatement{Label: start}, end)
+}
+
+// типы коллекций с доступом по индексу, имена в нижнем регистре
+var indexedTypes = map[string]struct{}{
+	"массив":              {},
+	"фиксированныймассив": {},
+	"таблицазначений":     {},
+	"списокзначений":      {},
+}
+
+// функции и методы, которые возвращают коллекции с доступом по индексу
+var indexedMethods = map[string]struct{}{
+	"стрразделить":      {},
+	"выгрузить":         {},
+	"выгрузитьколонку":  {},
+	"выгрузитьзначения": {},
+	"найтистроки":       {},
+}
+
+// indexed есть ли у коллекции доступ по индексу и Количество(). Переменная подходит, если это не параметр метода
+// и все присваивания ей в методе дают такую коллекцию
+func (c *Obfuscator) indexed(collection ast.Statement) bool {
+	switch v := collection.(type) {
+	case ast.NewObjectStatement:
+		_, ok := indexedTypes[strings.ToLower(v.Constructor)]
+		return ok
+	case *ast.NewObjectStatement:
+		return v != nil && c.indexed(*v)
+	case ast.MethodStatement:
+		_, ok := indexedMethods[strings.ToLower(v.Name)]
+		return ok
+	case ast.CallChainStatement:
+		m, ok := v.Unit.(ast.MethodStatement)
+		return ok && c.indexed(m)
+	case ast.VarStatement:
+		if c.current == nil {
+			return false
+		}
+		for _, p := range c.current.Params {
+			if strings.EqualFold(p.Name, v.Name) {
+				return false
+			}
+		}
+
+		assigned, indexed := false, true
+		var walk func(body ast.Statements)
+		walk = func(body ast.Statements) {
+			for _, stm := range body {
+				if exp, ok := stm.(*ast.ExpStatement); ok && exp.Operation == ast.OpEq {
+					if left, ok := exp.Left.(ast.VarStatement); ok && strings.EqualFold(left.Name, v.Name) {
+						assigned, indexed = true, indexed && c.indexed(exp.Right)
+					}
+				}
+				eachBody(stm, func(nested *ast.Statements) bool {
+					walk(*nested)
+					return false
+				})
+			}
+		}
+		walk(c.current.Body)
+
+		return assigned && indexed
+	}
+
+	return false
+}
+
+// newLabel метка с именем, уникальным в пределах модуля. Если короткие имена заканчиваются, длина увеличивается
+func (c *Obfuscator) newLabel() *ast.GoToLabelStatement {
+	if c.labels == nil {
+		c.labels = map[string]struct{}{}
+	}
+
+	for n := 0; ; n++ {
+		name := c.randomString(c.intensity.Names.Label + n/10)
+		if _, ok := c.labels[name]; !ok {
+			c.labels[name] = struct{}{}
+			return &ast.GoToLabelStatement{Name: name}
+		}
+	}
+}
+
+// increment счетчик = счетчик + 1
+func increment(counter ast.Statement) *ast.ExpStatement {
+	return &ast.ExpStatement{
+		Operation: ast.OpEq,
+		Left:      counter,
+		Right: &ast.ExpStatement{
+			Operation: ast.OpPlus,
+			Left:      counter,
+			Right:     float64(1),
+		},
+	}
+}
+
+// replaceJumps заменяет Продолжить и Прервать на переходы. Во вложенные циклы не заходит, у них свои переходы
+func replaceJumps(body ast.Statements, continueLabel, breakLabel *ast.GoToLabelStatement) {
+	for i, stm := range body {
+		switch stm.(type) {
+		case ast.ContinueStatement, *ast.ContinueStatement:
+			body[i] = ast.GoToStatement{Label: continueLabel}
+		case ast.BreakStatement, *ast.BreakStatement:
+			body[i] = ast.GoToStatement{Label: breakLabel}
+		case ast.LoopStatement, *ast.LoopStatement:
+		default:
+			eachBody(stm, func(nested *ast.Statements) bool {
+				replaceJumps(*nested, continueLabel, breakLabel)
+				return false
+			})
+		}
+	}
+}
+
+// eachBody вызывает fn для вложенных блоков конструкции (ветки Если, тело цикла, Попытка/Исключение).
+// Если fn вернула true, обход прекращается
+func eachBody(stm ast.Statement, fn func(body *ast.Statements) bool) bool {
+	v := reflect.ValueOf(stm)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return false
+	}
+
+	bodyType := reflect.TypeOf(ast.Statements{})
+	s := v.Elem()
+	for i := 0; i < s.NumField(); i++ {
+		if f := s.Field(i); f.Type() == bodyType && f.CanSet() {
+			if fn(f.Addr().Interface().(*ast.Statements)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (c *Obfuscator) invertExp(exp ast.Statement) ast.Statement {
+	switch v := exp.(type) {
+	case ast.INot:
+		return v.Not()
+	case bool:
+		return !v
+	default:
+		// вызовы методов, переменные и прочие выражения без собственного отрицания
+		return ast.NotStatement{Param: exp}
+	}
+}
+
+// replaceLoopToGoto заменяет цикл на переходы, цикл ищется в том числе во вложенных блоках
+func (c *Obfuscator) replaceLoopToGoto(body *ast.Statements, loop *ast.LoopStatement) bool {
+	for i, stm := range *body {
+		if l, ok := stm.(*ast.LoopStatement); ok && l == loop {
+			*body = append(append(append(ast.Statements{}, (*body)[:i]...), c.loopToGoto(loop)...), (*body)[i+1:]...)
+			return true
+		}
+	}
+
+	for _, stm := range *body {
+		if eachBody(stm, func(nested *ast.Statements) bool { return c.replaceLoopToGoto(nested, loop) }) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Obfuscator) replaceAllLoopToGoto(body *ast.Statements) {
+	for i := len(*body) - 1; i >= 0; i-- {
+		var newStatements ast.Statements
+
+		switch v := (*body)[i].(type) {
+		case *ast.LoopStatement:
+			newStatements = c.loopToGoto(v)
+		case ast.LoopStatement:
+			newStatements = c.loopToGoto(&v)
+		}
+
+		if newStatements != nil {
+			*body = append(append(append(ast.Statements{}, (*body)[:i]...), newStatements...), (*body)[i+1:]...)
+		}
+	}
+}
+
+// printInline текст выражения без завершающей точки с запятой
+func (c *Obfuscator) printInline(stm ast.Statement) string {
+	return strings.TrimSuffix(strings.TrimSpace(c.a.PrintStatementWithConf(stm, ast.PrintConf{})), ";")
+}
+
+// parseStatements разбирает служебный код
+func (c *Obfuscator) parseStatements(code string) (ast.Statements, bool) {
+	astObj := ast.NewAST(code)
+	if err := astObj.Parse(); err != nil {
+		return nil, false
+	}
+
+	return astObj.ModuleStatement.Body, true
+}
@@ -0,0 +1,126 @@
+package obfuscator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplaceJumps(t *testing.T) {
+	inner := &ast.LoopStatement{
+		WhileExpr: true,
+		Body:      ast.Statements{ast.BreakStatement{}},
+	}
+	body := ast.Statements{
+		&ast.IfStatement{
+			Expression: true,
+			TrueBlock:  ast.Statements{ast.ContinueStatement{}},
+			ElseBlock:  ast.Statements{ast.BreakStatement{}},
+		},
+		inner,
+	}
+
+	next, end := &ast.GoToLabelStatement{Name: "next"}, &ast.GoToLabelStatement{Name: "end"}
+	replaceJumps(body, next, end)
+
+	IF := body[0].(*ast.IfStatement)
+	assert.Equal(t, ast.GoToStatement{Label: next}, IF.TrueBlock[0])
+	assert.Equal(t, ast.GoToStatement{Label: end}, IF.ElseBlock[0])
+	// Прервать вложенного цикла относится к нему
+	assert.Equal(t, ast.BreakStatement{}, inner.Body[0])
+
+	c := &Obfuscator{}
+	outer := &ast.LoopStatement{WhileExpr: true, Body: ast.Statements{&ast.IfStatement{Expression: true, TrueBlock: ast.Statements{inner}}}}
+	fpBody := ast.Statements{outer}
+	c.intensity = DefaultIntensity()
+	assert.True(t, c.replaceLoopToGoto(&fpBody, inner))
+	assert.Equal(t, outer, fpBody[0])
+	assert.NotContains(t, outer.Body[0].(*ast.IfStatement).TrueBlock, ast.Statement(inner))
+}
+
+func TestLoopToGoto(t *testing.T) {
+	code := `Процедура Тест(Коллекция, Соответствие)
+	Строки = СтрРазделить("1,2,3", ",");
+	Для Сч = 1 По Коллекция.Количество() Цикл
+		Если Сч = 2 Тогда
+			Продолжить;
+		КонецЕсли;
+		Для Каждого Элемент Из Строки Цикл
+			Если Элемент = Сч Тогда
+				Прервать;
+			КонецЕсли;
+		КонецЦикла;
+		Пока Истина Цикл
+			Прервать;
+		КонецЦикла;
+	КонецЦикла;
+КонецПроцедуры`
+
+	obf := NewObfuscatory(context.Background(), Config{
+		RepLoopByGoto: true,
+		Intensity:     Intensity{Goto: Level{Probability: ptr(1.0)}},
+	})
+	obCode, err := obf.Obfuscate(code)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NotContains(t, obCode, "Прервать")
+	assert.NotContains(t, obCode, "Продолжить")
+	assert.NotContains(t, obCode, "Пока")
+	assert.NotContains(t, obCode, "Для Сч")
+	assert.NotContains(t, obCode, "Для Каждого")
+	// граница вычисляется один раз
+	assert.Equal(t, 1, strings.Count(obCode, "Коллекция.Количество()"))
+}
+
+func TestWhileConditionInverted(t *testing.T) {
+	for _, cond := range []ast.Statement{
+		ast.MethodStatement{Name: "ЕстьДанные", Param: ast.ExprStatements{Statements: ast.Statements{ast.VarStatement{Name: "Выборка"}}}},
+		ast.VarStatement{Name: "Продолжать"},
+	} {
+		c := &Obfuscator{}
+		c.intensity = DefaultIntensity()
+
+		body := c.loopToGoto(&ast.LoopStatement{WhileExpr: cond, Body: ast.Statements{ast.BreakStatement{}}})
+		if !assert.Len(t, body, 5) {
+			continue
+		}
+
+		IF, ok := body[1].(*ast.IfStatement)
+		if assert.True(t, ok) {
+			assert.Equal(t, ast.NotStatement{Param: cond}, IF.Expression)
+		}
+	}
+}
+
+func TestForEachIndexed(t *testing.T) {
+	param := ast.VarStatement{Name: "Соответствие"}
+	rows := ast.VarStatement{Name: "Строки"}
+	c := &Obfuscator{current: &ast.FunctionOrProcedure{
+		Params: []ast.ParamStatement{{Name: "Соответствие"}},
+		Body: ast.Statements{
+			&ast.ExpStatement{Operation: ast.OpEq, Left: rows, Right: ast.NewObjectStatement{Constructor: "Массив"}},
+			&ast.IfStatement{
+				Expression: &ast.ExpStatement{Operation: ast.OpEq, Left: rows, Right: float64(0)},
+				TrueBlock:  ast.Statements{&ast.ExpStatement{Operation: ast.OpEq, Left: rows, Right: ast.MethodStatement{Name: "СтрРазделить"}}},
+			},
+		},
+	}}
+
+	assert.True(t, c.indexed(rows))
+	assert.True(t, c.indexed(ast.NewObjectStatement{Constructor: "ТаблицаЗначений"}))
+	assert.True(t, c.indexed(ast.CallChainStatement{Unit: ast.MethodStatement{Name: "Выгрузить"}, Call: ast.VarStatement{Name: "Результат"}}))
+	assert.False(t, c.indexed(param))
+	assert.False(t, c.indexed(ast.NewObjectStatement{Constructor: "Соответствие"}))
+	assert.False(t, c.indexed(ast.VarStatement{Name: "Неизвестная"}))
+
+	c.current.Body = append(c.current.Body, &ast.IfStatement{
+		Expression: true,
+		ElseBlock:  ast.Statements{&ast.ExpStatement{Operation: ast.OpEq, Left: rows, Right: ast.NewObjectStatement{Constructor: "Структура"}}},
+	})
+	assert.False(t, c.indexed(rows))
+}
@@ -0,0 +1,39 @@
+package obfuscator
+
+import (
+	"github.com/LazarenkoA/1c-language-parser/ast"
+)
+
+// mainProgram код основной программы (операторы модуля вне методов) в виде метода,
+// чтобы к нему применялись те же преобразования, что и к методам. nil - кода основной программы нет
+func (c *Obfuscator) mainProgram(module *ast.ModuleStatement) *ast.FunctionOrProcedure {
+	var body ast.Statements
+	for _, stm := range module.Body {
+		if _, ok := stm.(*ast.FunctionOrProcedure); !ok {
+			body = append(body, stm)
+		}
+	}
+
+	if len(body) == 0 {
+		return nil
+	}
+
+	// основная программа модуля формы выполняется на клиенте
+	return &ast.FunctionOrProcedure{
+		Type:      ast.PFTypeProcedure,
+		Directive: ast.IF(c.module.Kind == ModuleForm, "&НаКлиенте", ""),
+		Body:      body,
+	}
+}
+
+// setMainProgram возвращает обработанный код основной программы в модуль, после всех методов
+func (c *Obfuscator) setMainProgram(module *ast.ModuleStatement, main *ast.FunctionOrProcedure) {
+	var body ast.Statements
+	for _, stm := range module.Body {
+		if _, ok := stm.(*ast.FunctionOrProcedure); ok {
+			body = append(body, stm)
+		}
+	}
+
+	module.Body = append(body, main.Body...)
+}
@@ -0,0 +1,171 @@
+package obfuscator
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ссылки на методы в описаниях объектов метаданных:
+//
+//	EventSubscriptions/*.xml  <Handler>CommonModule.Модуль.Метод</Handler>
+//	ScheduledJobs/*.xml       <MethodName>CommonModule.Модуль.Метод</MethodName>
+//	HTTPServices/*.xml        <Handler>Метод</Handler> - метод модуля сервиса
+//	WebServices/*.xml         <ProcedureName>Метод</ProcedureName> - метод модуля сервиса
+var metadataHandlerRe = regexp.MustCompile(`(<(Handler|MethodName|ProcedureName)>)(\s*)([^<\s]+)(\s*)(</(?:Handler|MethodName|ProcedureName)>)`)
+
+// metadataRef ссылка из описания объекта метаданных на метод модуля
+type metadataRef struct {
+	descriptor string
+	module     string
+	method     string
+}
+
+// metadataIndex ссылки на методы из описаний объектов метаданных выгрузки
+type metadataIndex struct {
+	refs []metadataRef
+
+	// sources тексты всех модулей выгрузки, нужны чтобы понять, вызывается ли экспортный метод из других модулей
+	sources map[string]string
+}
+
+// loadMetadataIndex читает описания подписок на события, регламентных заданий, HTTP и web-сервисов
+func loadMetadataIndex(root string, withSources bool) (*metadataIndex, error) {
+	index := &metadataIndex{sources: map[string]string{}}
+
+	err := filepath.WalkDir(root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		switch {
+		case withSources && strings.EqualFold(path.Ext(rel), ".bsl"):
+			data, err := os.ReadFile(filePath)
+			if err != nil {
+				return err
+			}
+			index.sources[strings.ToLower(rel)] = string(data)
+		case isMetadataDescriptor(rel):
+			data, err := os.ReadFile(filePath)
+			if err != nil {
+				return err
+			}
+
+			for _, m := range metadataHandlerRe.FindAllStringSubmatch(string(data), -1) {
+				if module, method, ok := handlerRef(rel, m[2], m[4]); ok {
+					index.refs = append(index.refs, metadataRef{descriptor: rel, module: module, method: method})
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return index, errors.Wrap(err, "read metadata error")
+}
+
+// isMetadataDescriptor файл описания объекта, который может ссылаться на методы модулей (Каталог/Имя.xml)
+func isMetadataDescriptor(rel string) bool {
+	parts := strings.Split(rel, "/")
+	if len(parts) != 2 || !strings.EqualFold(path.Ext(parts[1]), ".xml") {
+		return false
+	}
+
+	switch parts[0] {
+	case "EventSubscriptions", "ScheduledJobs", "HTTPServices", "WebServices":
+		return true
+	}
+
+	return false
+}
+
+// handlerRef модуль и метод, на которые ссылается значение тега описания
+func handlerRef(descriptor, tag, value string) (module, method string, ok bool) {
+	dir, file := path.Split(descriptor)
+	dir = strings.TrimSuffix(dir, "/")
+	name := strings.TrimSuffix(file, path.Ext(file))
+
+	switch {
+	case (dir == "EventSubscriptions" && tag == "Handler") || (dir == "ScheduledJobs" && tag == "MethodName"):
+		parts := strings.Split(value, ".")
+		if len(parts) != 3 || !(strings.EqualFold(parts[0], "CommonModule") || strings.EqualFold(parts[0], "ОбщийМодуль")) {
+			return "", "", false
+		}
+
+		return path.Join("CommonModules", parts[1], "Ext", "Module.bsl"), parts[2], true
+	case (dir == "HTTPServices" && tag == "Handler") || (dir == "WebServices" && tag == "ProcedureName"):
+		return path.Join(dir, name, "Ext", "Module.bsl"), value, true
+	}
+
+	return "", "", false
+}
+
+// methods методы модуля, на которые есть ссылки из описаний метаданных
+func (m *metadataIndex) methods(modulePath string) []string {
+	var result []string
+	for _, ref := range m.refs {
+		if strings.EqualFold(ref.module, modulePath) {
+			result = append(result, ref.method)
+		}
+	}
+
+	return result
+}
+
+// calledOutside встречается ли имя метода в других модулях. Проверка намеренно грубая:
+// любое упоминание (вызов через точку, строка для Выполнить() и т.п.) запрещает переименование
+func (m *metadataIndex) calledOutside(modulePath, method string) bool {
+	re := regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}_])` + regexp.QuoteMeta(method) + `(?:[^\p{L}\p{N}_]|$)`)
+	for name, source := range m.sources {
+		if name != strings.ToLower(modulePath) && re.MatchString(source) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// descriptors описания, которые ссылаются на переименованные методы
+func (m *metadataIndex) descriptors(renames map[string]map[string]string) map[string]struct{} {
+	result := map[string]struct{}{}
+	for _, ref := range m.refs {
+		if _, ok := renames[strings.ToLower(ref.module)][strings.ToLower(ref.method)]; ok {
+			result[ref.descriptor] = struct{}{}
+		}
+	}
+
+	return result
+}
+
+// renameMetadataHandlers заменяет имена методов в описании объекта,
+// renames - путь модуля в нижнем регистре -> старое имя метода в нижнем регистре -> новое
+func renameMetadataHandlers(descriptor string, data []byte, renames map[string]map[string]string) []byte {
+	return metadataHandlerRe.ReplaceAllFunc(data, func(match []byte) []byte {
+		parts := metadataHandlerRe.FindSubmatch(match)
+		value := string(parts[4])
+
+		module, method, ok := handlerRef(descriptor, string(parts[2]), value)
+		if !ok {
+			return match
+		}
+
+		newName, ok := renames[strings.ToLower(module)][strings.ToLower(method)]
+		if !ok {
+			return match
+		}
+
+		value = strings.TrimSuffix(value, method) + newName
+		return []byte(string(parts[1]) + string(parts[3]) + value + string(parts[5]) + string(parts[6]))
+	})
+}
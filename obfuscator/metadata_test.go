@@ -0,0 +1,119 @@
+package obfuscator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSubscriptionXML = `<?xml version="1.0" encoding="UTF-8"?>
+<MetaDataObject xmlns="http://v8.1c.ru/8.3/MDClasses">
+	<EventSubscription uuid="0b2c8a4e-6a0f-4d8c-9b1a-1f0e7c2d3a4b">
+		<Properties>
+			<Name>ПроверкаПередЗаписью</Name>
+			<Event>BeforeWrite</Event>
+			<Handler>CommonModule.Подписки.ПроверкаПередЗаписью</Handler>
+		</Properties>
+	</EventSubscription>
+</MetaDataObject>`
+
+const testScheduledJobXML = `<?xml version="1.0" encoding="UTF-8"?>
+<MetaDataObject xmlns="http://v8.1c.ru/8.3/MDClasses">
+	<ScheduledJob uuid="3f1d2c4b-5a6e-4f70-8a9b-0c1d2e3f4a5b">
+		<Properties>
+			<Name>Очистка</Name>
+			<MethodName>CommonModule.Подписки.Очистка</MethodName>
+		</Properties>
+	</ScheduledJob>
+</MetaDataObject>`
+
+const testHTTPServiceXML = `<?xml version="1.0" encoding="UTF-8"?>
+<MetaDataObject xmlns="http://v8.1c.ru/8.3/MDClasses">
+	<HTTPService uuid="7a6b5c4d-3e2f-4a1b-9c8d-7e6f5a4b3c2d">
+		<ChildObjects>
+			<URLTemplate uuid="1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d">
+				<ChildObjects>
+					<Method uuid="2b3c4d5e-6f7a-4b8c-9d0e-1f2a3b4c5d6e">
+						<Properties>
+							<Name>GET</Name>
+							<HTTPMethod>GET</HTTPMethod>
+							<Handler>ПолучитьДанные</Handler>
+						</Properties>
+					</Method>
+				</ChildObjects>
+			</URLTemplate>
+		</ChildObjects>
+	</HTTPService>
+</MetaDataObject>`
+
+const testCommonModule = `Процедура ПроверкаПередЗаписью(Источник, Отказ) Экспорт
+КонецПроцедуры
+
+Процедура Очистка() Экспорт
+КонецПроцедуры`
+
+func TestHandlerRef(t *testing.T) {
+	module, method, ok := handlerRef("EventSubscriptions/Подписка.xml", "Handler", "CommonModule.Подписки.Метод")
+	assert.True(t, ok)
+	assert.Equal(t, "CommonModules/Подписки/Ext/Module.bsl", module)
+	assert.Equal(t, "Метод", method)
+
+	module, method, ok = handlerRef("HTTPServices/API.xml", "Handler", "ПолучитьДанные")
+	assert.True(t, ok)
+	assert.Equal(t, "HTTPServices/API/Ext/Module.bsl", module)
+	assert.Equal(t, "ПолучитьДанные", method)
+
+	_, _, ok = handlerRef("ScheduledJobs/Очистка.xml", "Handler", "CommonModule.Подписки.Метод")
+	assert.False(t, ok)
+
+	assert.True(t, isMetadataDescriptor("WebServices/Обмен.xml"))
+	assert.False(t, isMetadataDescriptor("WebServices/Обмен/Ext/Module.bsl"))
+	assert.False(t, isMetadataDescriptor("Catalogs/Товары.xml"))
+}
+
+func TestObfuscateDirMetadata(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		"EventSubscriptions/ПроверкаПередЗаписью.xml": testSubscriptionXML,
+		"ScheduledJobs/Очистка.xml":                   testScheduledJobXML,
+		"HTTPServices/API.xml":                        testHTTPServiceXML,
+		"CommonModules/Подписки/Ext/Module.bsl":       testCommonModule,
+		"HTTPServices/API/Ext/Module.bsl":             "Функция ПолучитьДанные(Запрос)\n\tВозврат Неопределено;\nКонецФункции",
+		"CommonModules/Другой/Ext/Module.bsl":         "Процедура Тест() Экспорт\n\tПодписки.Очистка();\nКонецПроцедуры",
+	}
+	for name, data := range files {
+		assert.NoError(t, writeFile(filepath.Join(src, filepath.FromSlash(name)), []byte(data)))
+	}
+
+	err := ObfuscateDir(context.Background(), src, dst, BatchOptions{
+		ConfigFor: func(string) (Config, bool, error) {
+			return Config{RenameMethods: true}, true, nil
+		},
+		MetadataHandlers: HandlersRename,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	read := func(name string) string {
+		data, _ := os.ReadFile(filepath.Join(dst, filepath.FromSlash(name)))
+		return string(data)
+	}
+
+	module := read("CommonModules/Подписки/Ext/Module.bsl")
+	assert.NotContains(t, module, "ПроверкаПередЗаписью")
+	assert.Contains(t, module, "Очистка") // вызывается из другого модуля
+
+	subscription := read("EventSubscriptions/ПроверкаПередЗаписью.xml")
+	assert.NotContains(t, subscription, "CommonModule.Подписки.ПроверкаПередЗаписью")
+	assert.Contains(t, subscription, "<Handler>CommonModule.Подписки.")
+	assert.Contains(t, subscription, "<Name>ПроверкаПередЗаписью</Name>")
+
+	assert.Equal(t, testScheduledJobXML, read("ScheduledJobs/Очистка.xml"))
+
+	assert.NotContains(t, read("HTTPServices/API/Ext/Module.bsl"), "ПолучитьДанные")
+	assert.NotContains(t, read("HTTPServices/API.xml"), "<Handler>ПолучитьДанные</Handler>")
+}
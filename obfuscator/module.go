@@ -0,0 +1,175 @@
+package obfuscator
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CompilationContext контекст, в котором компилируется модуль
+type CompilationContext string
+
+const (
+	ContextClient             CompilationContext = "client"
+	ContextServer             CompilationContext = "server"
+	ContextExternalConnection CompilationContext = "externalConnection"
+)
+
+// ModuleInfo что известно о модуле
+type ModuleInfo struct {
+	// Kind тип модуля, если не задан, определяется по Path
+	Kind ModuleKind
+
+	// Path путь модуля в выгрузке конфигурации в файлы
+	Path string
+
+	// CompilationContext где компилируется модуль, если не задан, определяется по типу модуля
+	CompilationContext []CompilationContext
+}
+
+// модули объектов выгрузки: имя файла -> тип модуля
+var moduleFiles = map[string]ModuleKind{
+	"objectmodule.bsl":              ModuleObject,
+	"recordsetmodule.bsl":           ModuleRecordSet,
+	"valuemanagermodule.bsl":        ModuleValueManager,
+	"managermodule.bsl":             ModuleManager,
+	"commandmodule.bsl":             ModuleCommand,
+	"sessionmodule.bsl":             ModuleSession,
+	"managedapplicationmodule.bsl":  ModuleApplication,
+	"ordinaryapplicationmodule.bsl": ModuleApplication,
+	"externalconnectionmodule.bsl":  ModuleExternalConnection,
+}
+
+// ModuleKindByPath тип модуля по пути в выгрузке конфигурации в файлы
+func ModuleKindByPath(modulePath string) ModuleKind {
+	modulePath = path.Clean(strings.ReplaceAll(modulePath, "\\", "/"))
+	if formXMLPath(modulePath) != "" {
+		return ModuleForm
+	}
+
+	file := strings.ToLower(path.Base(modulePath))
+	if kind, ok := moduleFiles[file]; ok {
+		return kind
+	}
+
+	// CommonModules/Имя/Ext/Module.bsl
+	parts := strings.Split(modulePath, "/")
+	if file == "module.bsl" && len(parts) >= 4 && strings.EqualFold(parts[len(parts)-4], "CommonModules") {
+		return ModuleCommon
+	}
+
+	return ModuleUnknown
+}
+
+// известные типы модулей
+var moduleKinds = []ModuleKind{
+	ModuleObject, ModuleRecordSet, ModuleValueManager, ModuleManager, ModuleForm, ModuleCommand,
+	ModuleSession, ModuleApplication, ModuleExternalConnection, ModuleCommon,
+}
+
+// ParseModuleKind тип модуля по имени без учета регистра (form, recordSet и т.д.), пустое имя - неизвестный тип
+func ParseModuleKind(name string) (ModuleKind, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ModuleUnknown, nil
+	}
+
+	for _, kind := range moduleKinds {
+		if strings.EqualFold(string(kind), name) {
+			return kind, nil
+		}
+	}
+
+	return ModuleUnknown, errors.Errorf("unknown module kind %q", name)
+}
+
+// withDefaults проверяет тип модуля и заполняет тип и контекст компиляции, если они не заданы
+func (m ModuleInfo) withDefaults() (ModuleInfo, error) {
+	kind, err := ParseModuleKind(string(m.Kind))
+	if err != nil {
+		return m, err
+	}
+
+	m.Kind = kind
+	if m.Kind == ModuleUnknown && m.Path != "" {
+		m.Kind = ModuleKindByPath(m.Path)
+	}
+	if len(m.CompilationContext) > 0 {
+		return m, nil
+	}
+
+	switch m.Kind {
+	case ModuleObject, ModuleRecordSet, ModuleValueManager, ModuleManager, ModuleSession:
+		m.CompilationContext = []CompilationContext{ContextServer, ContextExternalConnection}
+	case ModuleCommand, ModuleApplication:
+		m.CompilationContext = []CompilationContext{ContextClient}
+	case ModuleExternalConnection:
+		m.CompilationContext = []CompilationContext{ContextExternalConnection}
+	case ModuleForm, ModuleCommon:
+		m.CompilationContext = []CompilationContext{ContextClient, ContextServer, ContextExternalConnection}
+	}
+
+	return m, nil
+}
+
+// CompiledIn компилируется ли модуль в указанном контексте, для модуля неизвестного или неправильного типа всегда true
+func (m ModuleInfo) CompiledIn(context CompilationContext) bool {
+	m, err := m.withDefaults()
+	if err != nil || len(m.CompilationContext) == 0 {
+		return true
+	}
+
+	for _, c := range m.CompilationContext {
+		if c == context {
+			return true
+		}
+	}
+
+	return false
+}
+
+// directivesAllowed допустимы ли в модуле директивы компиляции (&НаКлиенте, &НаСервере и т.д.)
+func (m ModuleInfo) directivesAllowed() bool {
+	return m.Kind == ModuleForm || m.Kind == ModuleCommand || m.Kind == ModuleUnknown
+}
+
+// exportsVisible доступны ли экспортные методы модуля из других модулей.
+// Экспортные методы модуля команды и модуля сеанса снаружи не вызываются
+func (m ModuleInfo) exportsVisible() bool {
+	return m.Kind != ModuleCommand && m.Kind != ModuleSession
+}
+
+// свойства общего модуля в CommonModules/Имя.xml
+var commonModulePropRe = regexp.MustCompile(`<(Server|ClientManagedApplication|ClientOrdinaryApplication|ExternalConnection)>\s*true\s*</`)
+
+// commonModuleContexts контекст компиляции общего модуля по его описанию
+func commonModuleContexts(data []byte) []CompilationContext {
+	used := map[CompilationContext]bool{}
+	for _, m := range commonModulePropRe.FindAllSubmatch(data, -1) {
+		switch string(m[1]) {
+		case "Server":
+			used[ContextServer] = true
+		case "ClientManagedApplication", "ClientOrdinaryApplication":
+			used[ContextClient] = true
+		case "ExternalConnection":
+			used[ContextExternalConnection] = true
+		}
+	}
+
+	var result []CompilationContext
+	for _, c := range []CompilationContext{ContextClient, ContextServer, ContextExternalConnection} {
+		if used[c] {
+			result = append(result, c)
+		}
+	}
+
+	return result
+}
+
+// commonModuleXMLPath путь к описанию общего модуля (CommonModules/Имя/Ext/Module.bsl -> CommonModules/Имя.xml)
+func commonModuleXMLPath(modulePath string) string {
+	dir := path.Dir(path.Dir(path.Clean(strings.ReplaceAll(modulePath, "\\", "/"))))
+	return dir + ".xml"
+}
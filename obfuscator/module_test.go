@@ -0,0 +1,119 @@
+package obfuscator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModuleKindByPath(t *testing.T) {
+	for p, kind := range map[string]ModuleKind{
+		"Catalogs/Товары/Ext/ObjectModule.bsl":                    ModuleObject,
+		"Catalogs/Товары/Ext/ManagerModule.bsl":                   ModuleManager,
+		"Catalogs/Товары/Forms/ФормаЭлемента/Ext/Form/Module.bsl": ModuleForm,
+		`CommonModules\ОбщегоНазначения\Ext\Module.bsl`:           ModuleCommon,
+		"Catalogs/Товары/Commands/Открыть/Ext/CommandModule.bsl":  ModuleCommand,
+		"InformationRegisters/Цены/Ext/RecordSetModule.bsl":       ModuleRecordSet,
+		"Ext/SessionModule.bsl":                                   ModuleSession,
+		"Ext/ManagedApplicationModule.bsl":                        ModuleApplication,
+		"HTTPServices/API/Ext/Module.bsl":                         ModuleUnknown,
+		"Module.bsl":                                              ModuleUnknown,
+	} {
+		assert.Equal(t, kind, ModuleKindByPath(p), p)
+	}
+
+	info := ModuleInfo{Path: "Catalogs/Товары/Ext/ObjectModule.bsl"}
+	assert.True(t, info.CompiledIn(ContextServer))
+	assert.False(t, info.CompiledIn(ContextClient))
+
+	contexts := commonModuleContexts([]byte(`<Properties>
+		<Global>false</Global>
+		<ClientManagedApplication>true</ClientManagedApplication>
+		<Server>true</Server>
+		<ExternalConnection>false</ExternalConnection>
+	</Properties>`))
+	assert.Equal(t, []CompilationContext{ContextClient, ContextServer}, contexts)
+	assert.Equal(t, "CommonModules/ОбщегоНазначения.xml", commonModuleXMLPath("CommonModules/ОбщегоНазначения/Ext/Module.bsl"))
+}
+
+func TestParseModuleKind(t *testing.T) {
+	for name, kind := range map[string]ModuleKind{"": ModuleUnknown, "form": ModuleForm, "Form": ModuleForm, "recordset": ModuleRecordSet} {
+		parsed, err := ParseModuleKind(name)
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, kind, parsed, name)
+		}
+	}
+
+	_, err := ParseModuleKind("froms")
+	assert.EqualError(t, err, `unknown module kind "froms"`)
+
+	// с неизвестным типом обработчики событий формы были бы переименованы
+	_, err = NewObfuscatory(context.Background(), Config{RenameMethods: true}).ObfuscateResult(`&НаСервере
+Процедура ПриСозданииНаСервере(Отказ, СтандартнаяОбработка)
+КонецПроцедуры`, ModuleInfo{Kind: "forms"})
+	assert.EqualError(t, err, `unknown module kind "forms"`)
+
+	info, err := ModuleInfo{Kind: "Form"}.withDefaults()
+	if assert.NoError(t, err) {
+		assert.Equal(t, ModuleForm, info.Kind)
+		assert.True(t, info.directivesAllowed())
+	}
+}
+
+func TestObfuscateModule(t *testing.T) {
+	code := `&НаСервере
+Процедура Тест()
+	Сообщить("тест");
+КонецПроцедуры`
+
+	obf := NewObfuscatory(context.Background(), Config{HideString: true})
+	obCode, err := obf.ObfuscateModule(code, ModuleInfo{Kind: ModuleForm})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, strings.Count(obCode, "&НаСервереБезКонтекста"))
+	}
+
+	obf = NewObfuscatory(context.Background(), Config{HideString: true})
+	obCode, err = obf.ObfuscateModule(code, ModuleInfo{Path: "CommonModules/Модуль/Ext/Module.bsl"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, strings.Count(obCode, "&НаСервере"))
+	}
+
+	code = `&НаКлиенте
+Процедура ОбработкаКоманды(ПараметрКоманды, ПараметрыВыполненияКоманды)
+	Открыть();
+КонецПроцедуры
+
+&НаКлиенте
+Процедура Открыть() Экспорт
+КонецПроцедуры`
+
+	obf = NewObfuscatory(context.Background(), Config{RenameMethods: true})
+	obCode, err = obf.ObfuscateModule(code, ModuleInfo{Kind: ModuleCommand})
+	if assert.NoError(t, err) {
+		assert.Contains(t, obCode, "ОбработкаКоманды")
+		assert.NotContains(t, obCode, "Открыть")
+	}
+}
+
+func TestHelperDirective(t *testing.T) {
+	form := &Obfuscator{module: ModuleInfo{Kind: ModuleForm}}
+	assert.Equal(t, "&НаКлиенте", form.directive("&НаКлиенте"))
+	assert.Equal(t, "&НаСервереБезКонтекста", form.directive("&НаСервере"))
+	assert.Equal(t, "&НаСервереБезКонтекста", form.directive("&НаСервереБезКонтекста"))
+	assert.Equal(t, "&НаСервереБезКонтекста", form.directive(""))
+	assert.Equal(t, "&НаКлиентеНаСервереБезКонтекста", form.directive("&НаКлиентеНаСервереБезКонтекста"))
+	assert.Equal(t, "&AtClient", form.directive("&AtClient"))
+
+	command := &Obfuscator{module: ModuleInfo{Kind: ModuleCommand}}
+	assert.Equal(t, "&НаСервере", command.directive("&НаСервере"))
+
+	common := &Obfuscator{module: ModuleInfo{Kind: ModuleCommon, CompilationContext: []CompilationContext{ContextClient}}}
+	assert.Equal(t, "", common.directive(""))
+	assert.Equal(t, []CompilationContext{ContextClient}, common.executionContext(""))
+
+	unknown := &Obfuscator{module: ModuleInfo{}}
+	assert.Equal(t, "", unknown.directive(""))
+	assert.Equal(t, "&НаСервереБезКонтекста", unknown.directive("&НаСервере"))
+}
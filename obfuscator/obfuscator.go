@@ -4,10 +4,12 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
-	"fmt"
 	"math/big"
+	mathrand "math/rand/v2"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/LazarenkoA/1c-language-parser/ast"
@@ -34,20 +36,93 @@ type Config struct {
 	// AppendGarbage добавлять мусора
 	AppendGarbage bool
 
+	// ExceptionFlow передавать результат условий Если через ВызватьИсключение внутри Попытка
+	ExceptionFlow bool
+
 	// ShuffleExpressions изменять порядок выражений
 	// ShuffleExpressions bool
 
 	// CallStackHell прятать выражения за большим количеством фейковых функций
 	CallStackHell bool
+
+	// RenameMethods переименовывать неэкспортные методы модуля
+	RenameMethods bool
+
+	// RenameVariables переименовывать неэкспортные переменные модуля
+	RenameVariables bool
+
+	// Regions что делать с областями (#Область): сохранить, удалить или заменить ложными
+	Regions RegionsMode
+
+	// PreservedNames имена методов, которые нельзя переименовывать, в дополнение к обработчикам событий платформы
+	PreservedNames []string
+
+	// Intensity глубина и вероятность преобразований, незаданные параметры берутся из DefaultIntensity
+	Intensity Intensity
+
+	// Passes порядок проходов (имена из RegisterTransform), один проход можно указать несколько раз.
+	// Если не задан, проходы определяются флагами выше
+	Passes []string
+
+	// Performance не применять к коду внутри циклов и к нагруженным методам проходы, которые замедляют выполнение:
+	// строки, Вычислить(), тернарные операторы, условия, мусор, исключения и CallStackHell
+	Performance bool
+
+	// HotMethods нагруженные методы, к ним применяются только преобразования без затрат при выполнении
+	// (переименование, Перейти). То же делает аннотация obfuscate:hot
+	HotMethods []string
+
+	// Target платформа, на которой выполняется код: версия, безопасный режим и клиентские приложения
+	Target Target
+
+	// Budget ограничения размера результата, при превышении интенсивность уменьшается
+	Budget Budget
+
+	// Logger получает диагностики (ошибки и предупреждения о непреобразованном коде) по мере их появления, не сериализуется
+	Logger Logger `yaml:"-" json:"-"`
+
+	// renameExports экспортные методы, которые можно переименовать: все ссылки на них известны (описания метаданных)
+	renameExports []string
 }
 
 type Obfuscator struct {
 	ctx                  context.Context
 	conf                 Config
+	intensity            Intensity
 	a                    *ast.AstNode
 	trueCondition        chan string
 	falseCondition       chan string
 	decodeStringFuncName map[string]string
+	generated            map[string]struct{}
+	pass                 string
+	annotations          map[string]*annotation
+	methods              map[string]*methodSettings
+	basePasses           []string
+	activePasses         map[string]struct{}
+	source               string
+	module               ModuleInfo
+	renames              map[string]string
+	varRenames           map[string]string
+	pre                  *preprocessor
+	placement            map[*ast.FunctionOrProcedure][]preBlock
+	extAnnotations       map[*ast.FunctionOrProcedure][]string
+	async                map[*ast.FunctionOrProcedure]bool
+	current              *ast.FunctionOrProcedure
+	evals                []evalCode
+	labels               map[string]struct{}
+	diag                 diagnostics
+	genErrors            genErrors
+	randomFailures       atomic.Int64 // сколько раз crypto/rand вернул ошибку
+	stats                map[string]int
+	scale                float64
+	sizes                map[*ast.FunctionOrProcedure]int
+	owners               map[*ast.FunctionOrProcedure]*ast.FunctionOrProcedure
+	generatedCount       int
+	hot                  bool
+	loopItems            map[*ast.Statement]struct{}
+	procStats            map[*ast.FunctionOrProcedure]*procStats
+	loopLabels           map[*ast.GoToLabelStatement]*ast.GoToLabelStatement
+	compat               map[string]struct{}
 }
 
 func init() {
@@ -58,9 +133,11 @@ func NewObfuscatory(ctx context.Context, conf Config) *Obfuscator {
 	c := &Obfuscator{
 		ctx:                  ctx,
 		conf:                 conf,
+		intensity:            conf.Intensity.withDefaults(),
 		trueCondition:        make(chan string, 10),
 		falseCondition:       make(chan string, 10),
 		decodeStringFuncName: make(map[string]string),
+		generated:            make(map[string]struct{}),
 	}
 
 	c.genCondition()
@@ -68,61 +145,158 @@ func NewObfuscatory(ctx context.Context, conf Config) *Obfuscator {
 }
 
 func (c *Obfuscator) Obfuscate(code string) (string, error) {
-	c.a = ast.NewAST(code)
+	return c.ObfuscateModule(code, ModuleInfo{})
+}
+
+// ObfuscateModule обфусцирует модуль с учетом его типа и контекста компиляции
+func (c *Obfuscator) ObfuscateModule(code string, info ModuleInfo) (string, error) {
+	result, err := c.ObfuscateResult(code, info)
+	return result.Code, err
+}
+
+// ObfuscateResult обфусцирует модуль и возвращает результат вместе с диагностиками: предупреждениями о коде,
+// который не удалось преобразовать, и ошибками. Если результат нельзя использовать, возвращается ошибка
+func (c *Obfuscator) ObfuscateResult(code string, info ModuleInfo) (Result, error) {
+	c.diag.reset()
+	c.source = code
+	module, err := info.withDefaults()
+	if err != nil {
+		return Result{}, err
+	}
+	c.module = module
+	failures := c.randomFailures.Load()
+	// ошибки генератора между запусками не относятся ни к одному модулю
+	c.genErrors.reset()
+
+	obCode, err := c.obfuscateWithinBudget(code)
+
+	if n := c.randomFailures.Load() - failures; n > 0 {
+		c.report(SeverityWarning, DiagRandom, nil, "crypto/rand failed %d times, math/rand was used", n)
+	}
+	for _, err := range c.genErrors.reset() {
+		c.report(SeverityWarning, DiagConditionGenerator, nil, "%v", err)
+	}
+
+	diagnostics := c.Diagnostics()
+	return Result{Code: obCode, Diagnostics: diagnostics, Report: c.buildReport(code, obCode, diagnostics)}, err
+}
+
+func (c *Obfuscator) obfuscate(code string) (string, error) {
+	c.renames = map[string]string{}
+	c.stats = map[string]int{}
+	c.decodeStringFuncName = map[string]string{}
+	c.owners = map[*ast.FunctionOrProcedure]*ast.FunctionOrProcedure{}
+	c.generatedCount = 0
+	c.procStats = map[*ast.FunctionOrProcedure]*procStats{}
+	c.loopLabels = map[*ast.GoToLabelStatement]*ast.GoToLabelStatement{}
+
+	annotations, err := parseAnnotations(code)
+	if err != nil {
+		return "", errors.Wrap(err, "annotation error")
+	}
+
+	if err := c.conf.Regions.validate(); err != nil {
+		return "", err
+	}
+	if err := c.conf.Target.validate(); err != nil {
+		return "", err
+	}
+
+	pre, err := parsePreprocessor(code)
+	if err != nil {
+		return "", errors.Wrap(err, "preprocessor error")
+	}
+
+	c.annotations = annotations
+	c.pre = pre
+	c.methods = map[string]*methodSettings{}
+	c.varRenames = map[string]string{}
+	c.evals = nil
+	c.labels = map[string]struct{}{}
+	for name := range pre.markers {
+		c.labels[name] = struct{}{}
+	}
+
+	c.a = ast.NewAST(pre.source)
 	if err := c.a.Parse(); err != nil {
 		return "", err
 	}
 
+	c.placePreprocessor(&c.a.ModuleStatement)
+	for _, item := range pre.controlled {
+		if item.await {
+			c.report(SeverityWarning, DiagAwaitSkipped, &ast.FunctionOrProcedure{Name: item.name}, "async method with Ждать is not obfuscated")
+		}
+	}
+	c.measureProcedures()
+	c.checkSourceCompatibility()
+
 	if len(c.a.ModuleStatement.Body) == 0 {
 		return code, nil
 	}
 
-	//hide := map[string]struct{}{}
-	c.a.ModuleStatement.Walk(func(root *ast.FunctionOrProcedure, parentStm, stm *ast.Statement) {
-		//if _, ok := hide[root.Name+root.Directive]; !ok {
-		//	for i := range root.Params {
-		//		root.Params[i].Name = c.randomString(int(random(5, 50)))
-		//	}
-		//	hide[root.Name+root.Directive] = struct{}{}
-		//}
+	if err := c.runPipeline(&c.a.ModuleStatement); err != nil {
+		return "", err
+	}
 
-		c.walkStep(root, parentStm, stm)
-	})
+	conf := ast.PrintConf{OneLine: true, Margin: 1}
+	var result string
+	if c.customPrint() {
+		result = c.printModule(conf)
+	} else {
+		result = c.a.Print(conf)
+		// result = strings.ToLower(result) // нельзя так делать, все поломает
+	}
+	result = c.pre.restoreMarkers(result)
+
+	// ошибки проверки попадают в диагностики, чтобы их видели Result.Diagnostics и отчет
+	if err := c.validateOutput(result); err != nil {
+		c.report(SeverityError, DiagValidation, nil, "%v", err)
+		return "", errors.Wrap(err, "output validation error")
+	}
+	if err := c.checkCompatibility(); err != nil {
+		return "", errors.Wrap(err, "compatibility error")
+	}
 
-	result := c.a.Print(ast.PrintConf{OneLine: true, Margin: 1})
-	// result = strings.ToLower(result) // нельзя так делать, все поломает
 	return result, nil
 }
 
 func (c *Obfuscator) walkStep(currentFP *ast.FunctionOrProcedure, parent, item *ast.Statement) {
 	if currentFP == nil {
-		fmt.Println("! you can obfuscate a procedure or function")
 		return
 	}
+	defer c.enterHot(parent, item)()
 
-	key := float64(random(10, 100))
+	key := float64(c.random(10, 100))
 
 	//c.hideParam(currentFP, item)
 
 	switch v := (*item).(type) {
 	case string:
-		if c.conf.HideString {
+		if c.is(PassStrings) {
 			*item = c.createObfuscateStringStatement(currentFP.Directive, v, int32(key))
 		}
 	case *ast.IfStatement:
 		c.walkStep(currentFP, item, &v.Expression)
 
-		v.Expression = c.appendConditions(v.Expression)
-		if c.conf.ChangeConditions {
-			c.appendIfElseBlock(&v.IfElseBlock, int(random(0, 5)))
+		if c.is(PassConditions) {
+			v.Expression = c.helperAppendConditions(v.Expression, c.depth(c.intensity.Conditions))
+			c.appendIfElseBlock(&v.IfElseBlock, int(c.random(0, c.intensity.Conditions.nesting())))
 			c.appendGarbage(&v.ElseBlock)
 			c.appendGarbage(&v.TrueBlock)
+			c.count(PassConditions)
+		}
+
+		if c.is(PassExceptions) && c.chance(c.intensity.Exceptions.probability()) {
+			c.ifToException(currentFP, v)
 		}
 
 		// v.TrueBlock = c.shuffleExpressions(v.TrueBlock)
 		// v.ElseBlock = c.shuffleExpressions(v.ElseBlock)
 	case *ast.FunctionOrProcedure:
-		c.appendGarbage(&v.Body)
+		if c.is(PassGarbage) {
+			c.garbage(&v.Body)
+		}
 		// v.Body = c.shuffleExpressions(v.Body)
 	case ast.MethodStatement:
 		for i, param := range v.Param.Statements {
@@ -130,21 +304,22 @@ func (c *Obfuscator) walkStep(currentFP *ast.FunctionOrProcedure, parent, item *
 			case *ast.ExpStatement, ast.MethodStatement:
 				c.walkStep(currentFP, item, &casted)
 			case string:
-				if c.conf.HideString {
+				if c.is(PassStrings) {
 					v.Param.Statements[i] = c.createObfuscateStringStatement(currentFP.Directive, casted, int32(key))
 				}
 			case ast.VarStatement:
-				if c.conf.RepExpByTernary {
-					v.Param.Statements[i] = c.hideValue(v.Param.Statements[i], 3)
+				if c.is(PassTernary) {
+					v.Param.Statements[i] = c.hideValue(v.Param.Statements[i])
 				}
 			}
 		}
 
-		if c.conf.RepExpByEval && parent == nil {
+		if c.is(PassEval) && parent == nil && c.chance(c.intensity.Eval.probability()) {
 			str := c.a.PrintStatementWithConf(v, ast.PrintConf{})
 			if str[len(str)-1] == ';' {
 				str = str[:len(str)-1]
 			}
+			c.addEval(str, false)
 
 			*item = ast.MethodStatement{
 				Name: "Выполнить",
@@ -154,50 +329,54 @@ func (c *Obfuscator) walkStep(currentFP *ast.FunctionOrProcedure, parent, item *
 			}
 		}
 	case *ast.ReturnStatement:
-		if str, ok := v.Param.(string); ok && c.conf.HideString {
+		if str, ok := v.Param.(string); ok && c.is(PassStrings) {
 			v.Param = c.createObfuscateStringStatement(currentFP.Directive, str, int32(key))
 		}
 	case *ast.ExpStatement:
 		c.obfuscateExpStatement(currentFP, (*interface{})(item))
 
-		if _, ok := v.Left.(ast.VarStatement); ok && c.conf.RepExpByEval {
+		if _, ok := v.Left.(ast.VarStatement); ok && c.is(PassEval) && c.chance(c.intensity.Eval.probability()) {
 			switch v.Right.(type) {
 			case ast.MethodStatement, ast.CallChainStatement, ast.NewObjectStatement:
 				str := c.a.PrintStatementWithConf(v.Right, ast.PrintConf{})
 				if str[len(str)-1] == ';' {
 					str = str[:len(str)-1]
 				}
+				c.addEval(str, true)
 
 				v.Right = ast.MethodStatement{
 					Name: "Вычислить",
 					Param: ast.ExprStatements{Statements: ast.Statements{ast.MethodStatement{
 						Name:  c.decodeStringFunc(currentFP.Directive),
-						Param: ast.ExprStatements{Statements: ast.Statements{c.obfuscateString(str, int32(key)), c.hideValue(key, 4)}},
+						Param: ast.ExprStatements{Statements: ast.Statements{c.obfuscateString(str, int32(key)), c.hideValue(key)}},
 					}}},
 				}
 			default:
-				v.Right = c.hideValue(v.Right, 4)
+				v.Right = c.hideValue(v.Right)
 			}
 		}
 	case ast.CallChainStatement:
-		if c.conf.RepExpByEval && (c.isMethod(parent) || c.isExp(parent) || c.isFP(parent)) {
+		if c.is(PassEval) && (c.isMethod(parent) || c.isExp(parent) || c.isFP(parent)) && c.chance(c.intensity.Eval.probability()) {
 			str := c.a.PrintStatementWithConf(v, ast.PrintConf{})
 			if str[len(str)-1] == ';' {
 				str = str[:len(str)-1]
 			}
+			c.addEval(str, c.isMethod(parent) || c.isExp(parent))
 
 			*item = ast.MethodStatement{
 				Name: ast.IF(c.isMethod(parent) || c.isExp(parent), "Вычислить", "Выполнить"),
 				Param: ast.ExprStatements{Statements: ast.Statements{
 					ast.MethodStatement{
 						Name:  c.decodeStringFunc(currentFP.Directive),
-						Param: ast.ExprStatements{Statements: ast.Statements{c.obfuscateString(str, int32(key)), c.hideValue(key, 4)}},
+						Param: ast.ExprStatements{Statements: ast.Statements{c.obfuscateString(str, int32(key)), c.hideValue(key)}},
 					},
 				}},
 			}
 		}
 	case *ast.LoopStatement:
-		c.replaceLoopToGoto(&currentFP.Body, v, false)
+		if c.is(PassGoto) && c.chance(c.intensity.Goto.probability()) {
+			c.replaceLoopToGoto(&currentFP.Body, v)
+		}
 	case ast.ExprStatements:
 		for i := range v.Statements {
 			c.walkStep(currentFP, item, &v.Statements[i])
@@ -206,12 +385,17 @@ func (c *Obfuscator) walkStep(currentFP *ast.FunctionOrProcedure, parent, item *
 		switch casted := v.Param.(type) {
 		case *ast.ExpStatement, ast.MethodStatement, ast.ExprStatements:
 			c.walkStep(currentFP, item, &casted)
+		case string:
+			if c.is(PassStrings) {
+				v.Param = c.createObfuscateStringStatement(currentFP.Directive, casted, int32(key))
+				*item = v
+			}
 		}
 	case ast.AssignmentStatement:
 		c.walkStep(currentFP, item, ptr(ast.Statement(v.Expr)))
 
-		if c.conf.CallStackHell {
-			c.hideBehindCallStack(currentFP.Directive, v.Expr, int(random(3, 7)))
+		if c.is(PassCallStack) && c.chance(c.intensity.CallStack.probability()) {
+			c.hideBehindCallStack(currentFP.Directive, v.Expr, c.depth(c.intensity.CallStack))
 		}
 	case ast.NewObjectStatement:
 		c.walkStep(currentFP, item, ptr(ast.Statement(v.Param)))
@@ -230,31 +414,28 @@ func (c *Obfuscator) hideParam(currentFP *ast.FunctionOrProcedure, item *ast.Sta
 }
 
 func (c *Obfuscator) obfuscateExpStatement(currentPF *ast.FunctionOrProcedure, part *interface{}) {
-	key := float64(random(10, 100))
+	key := float64(c.random(10, 100))
 
 	switch r := (*part).(type) {
 	case *ast.ExpStatement:
 		c.obfuscateExpStatement(currentPF, &r.Right)
 		c.obfuscateExpStatement(currentPF, &r.Left)
 
-		if c.conf.RepExpByTernary {
-			r.Right = c.hideValue(r.Right, 4)
+		if c.is(PassTernary) {
+			r.Right = c.hideValue(r.Right)
 		}
 	case string:
-		if c.conf.HideString {
+		if c.is(PassStrings) {
 			*part = c.createObfuscateStringStatement(currentPF.Directive, r, int32(key))
 		}
 		return
 	case ast.ReturnStatement:
-		if str, ok := r.Param.(string); ok && c.conf.HideString {
-			r.Param = ast.MethodStatement{
-				Name:  c.decodeStringFunc(currentPF.Directive),
-				Param: ast.ExprStatements{Statements: ast.Statements{c.obfuscateString(str, int32(key)), c.hideValue(key, 4)}},
-			}
+		if str, ok := r.Param.(string); ok && c.is(PassStrings) {
+			r.Param = c.createObfuscateStringStatement(currentPF.Directive, str, int32(key))
 		}
 	case ast.IParams:
 		for i, param := range r.Params() {
-			if str, ok := param.(string); ok && c.conf.HideString {
+			if str, ok := param.(string); ok && c.is(PassStrings) {
 				r.Params()[i] = c.createObfuscateStringStatement(currentPF.Directive, str, int32(key))
 			}
 		}
@@ -262,13 +443,15 @@ func (c *Obfuscator) obfuscateExpStatement(currentPF *ast.FunctionOrProcedure, p
 }
 
 func (c *Obfuscator) createObfuscateStringStatement(directive string, str string, key int32) ast.MethodStatement {
+	c.count(PassStrings)
 	return ast.MethodStatement{
 		Name:  c.decodeStringFunc(directive),
-		Param: ast.ExprStatements{Statements: ast.Statements{c.obfuscateString(str, key), c.hideValue(key, 4)}},
+		Param: ast.ExprStatements{Statements: ast.Statements{c.obfuscateString(str, key), c.hideValue(key)}},
 	}
 }
 
 func (c *Obfuscator) decodeStringFunc(directive string) string {
+	directive = c.directive(directive)
 	if name, ok := c.decodeStringFuncName[directive]; ok {
 		return name
 	} else {
@@ -279,57 +462,80 @@ func (c *Obfuscator) decodeStringFunc(directive string) string {
 	}
 }
 
-func (c *Obfuscator) hideValue(val interface{}, complexity int) ast.Statement {
+func (c *Obfuscator) hideValue(val interface{}) ast.Statement {
+	if !c.chance(c.intensity.Ternary.probability()) {
+		return val
+	}
+
 	switch val.(type) {
 	case string, bool, float64, int, int32, int64, float32, time.Time, *ast.ExpStatement, ast.MethodStatement, ast.VarStatement:
-		return c.newTernary(val, int(random(2, complexity)), int(random(0, complexity-1)))
+		c.count(PassTernary)
+		return c.newTernary(val, c.depth(c.intensity.Ternary), int(c.random(0, c.intensity.Ternary.MaxDepth-1)))
 	default:
 		return val
 	}
 }
 
+// appendGarbage добавляет мусор в сгенерированный код, если проход garbage есть в конвейере и разрешен для текущего метода
 func (c *Obfuscator) appendGarbage(body *ast.Statements) {
-	if !c.conf.AppendGarbage {
+	if _, ok := c.activePasses[PassGarbage]; !ok || !c.allowed(c.current, PassGarbage) {
 		return
 	}
 
-	if random(0, 2) == 1 {
+	c.garbage(body)
+}
+
+func (c *Obfuscator) garbage(body *ast.Statements) {
+	c.garbageNested(body, c.intensity.Garbage.nesting())
+}
+
+func (c *Obfuscator) garbageNested(body *ast.Statements, nesting int) {
+	p := c.intensity.Garbage.probability()
+
+	if c.chance(p) {
 		*body = append(*body, &ast.ExpStatement{
 			Operation: ast.OpEq,
-			Left:      ast.VarStatement{Name: c.randomString(20)},
-			Right:     c.hideValue(c.randomString(5), 4),
+			Left:      ast.VarStatement{Name: c.randomString(c.intensity.Names.Variable * 2)},
+			Right:     c.hideValue(c.randomString(5)),
 		})
+		c.count(PassGarbage)
 	}
-	if random(0, 2) == 1 {
+	if c.chance(p) {
 		*body = append(*body, &ast.ExpStatement{
 			Operation: ast.OpEq,
-			Left:      ast.VarStatement{Name: c.randomString(10)},
-			Right:     c.hideValue(float64(random(-100, 100)), 5),
+			Left:      ast.VarStatement{Name: c.randomString(c.intensity.Names.Variable)},
+			Right:     c.hideValue(float64(c.random(-100, 100))),
 		})
+		c.count(PassGarbage)
 	}
-	if random(0, 2) == 1 {
+	if nesting <= 0 {
+		return
+	}
+	if c.chance(p) {
 		IF := &ast.IfStatement{Expression: c.convStrExpToExpStatement(<-c.falseCondition)}
 
-		if random(0, 2) == 1 {
-			c.appendIfElseBlock(&IF.IfElseBlock, int(random(0, 5)))
+		if c.chance(p) {
+			c.appendIfElseBlock(&IF.IfElseBlock, int(c.random(0, c.intensity.Conditions.nesting())))
 		}
-		if random(0, 2) == 1 {
-			c.appendGarbage(&IF.ElseBlock)
-			c.appendGarbage(&IF.TrueBlock)
+		if c.chance(p) {
+			c.garbageNested(&IF.ElseBlock, nesting-1)
+			c.garbageNested(&IF.TrueBlock, nesting-1)
 		}
 
 		IF.TrueBlock = c.shuffleExpressions(IF.TrueBlock)
 		IF.ElseBlock = c.shuffleExpressions(IF.ElseBlock)
 		*body = append(*body, IF)
+		c.count(PassGarbage)
 	}
-	if random(0, 2) == 1 {
+	if c.chance(p) {
 		loop := &ast.LoopStatement{WhileExpr: c.convStrExpToExpStatement(<-c.falseCondition)}
-		if random(0, 2) == 1 {
-			c.appendGarbage(&loop.Body)
+		if c.chance(p) {
+			c.garbageNested(&loop.Body, nesting-1)
 		}
 
 		loop.Body = c.shuffleExpressions(loop.Body)
 		*body = append(*body, loop)
+		c.count(PassGarbage)
 	}
 }
 
@@ -341,14 +547,6 @@ func (c *Obfuscator) appendIfElseBlock(ifElseBlock *ast.Statements, count int) {
 	}
 }
 
-func (c *Obfuscator) appendConditions(exp ast.Statement) ast.Statement {
-	if !c.conf.ChangeConditions {
-		return exp
-	}
-
-	return c.helperAppendConditions(exp, 3)
-}
-
 func (c *Obfuscator) helperAppendConditions(exp ast.Statement, depth int) ast.Statement {
 	if depth == 0 {
 		return exp
@@ -360,7 +558,7 @@ func (c *Obfuscator) helperAppendConditions(exp ast.Statement, depth int) ast.St
 		Right:     c.convStrExpToExpStatement(<-c.trueCondition),
 	}
 
-	if random(0, 2) == 1 {
+	if c.random(0, 2) == 1 {
 		newConditions = &ast.ExpStatement{
 			Operation: ast.OpAnd,
 			Left:      c.convStrExpToExpStatement(<-c.trueCondition),
@@ -402,9 +600,9 @@ func (c *Obfuscator) newTernary(trueValue interface{}, depth, trueStep int) ast.
 func (c *Obfuscator) fakeValue(value interface{}) interface{} {
 	switch value.(type) {
 	case float64, float32, int, int32, int64:
-		return float64(random(0, 1000))
+		return float64(c.random(0, 1000))
 	case string:
-		return c.randomString(10)
+		return c.randomString(c.intensity.Names.Variable)
 	case *ast.ExpStatement:
 		return c.convStrExpToExpStatement(<-c.falseCondition)
 	case ast.MethodStatement:
@@ -419,35 +617,41 @@ func (c *Obfuscator) fakeMethods() ast.MethodStatement {
 	pool := []ast.MethodStatement{
 		{
 			Name:  "XMLСтрока",
-			Param: ast.ExprStatements{Statements: ast.Statements{float64(random(0, 1000))}},
+			Param: ast.ExprStatements{Statements: ast.Statements{float64(c.random(0, 1000))}},
 		},
 		{
 			Name:  "Лев",
-			Param: ast.ExprStatements{Statements: ast.Statements{c.randomString(20), float64(random(1, 10))}},
+			Param: ast.ExprStatements{Statements: ast.Statements{c.randomString(20), float64(c.random(1, 10))}},
 		},
 		{
 			Name:  "Прав",
-			Param: ast.ExprStatements{Statements: ast.Statements{c.randomString(20), float64(random(1, 10))}},
+			Param: ast.ExprStatements{Statements: ast.Statements{c.randomString(20), float64(c.random(1, 10))}},
 		},
 		{
 			Name:  "Сред",
-			Param: ast.ExprStatements{Statements: ast.Statements{c.randomString(20), float64(random(1, 10)), float64(random(0, 10))}},
+			Param: ast.ExprStatements{Statements: ast.Statements{c.randomString(20), float64(c.random(1, 10)), float64(c.random(0, 10))}},
 		},
+	}
+	if !c.conf.Target.bitwise() {
+		return pool[c.random(0, len(pool))]
+	}
+
+	pool = append(pool, []ast.MethodStatement{
 		{
 			Name:  "ПобитовыйСдвигВлево",
-			Param: ast.ExprStatements{Statements: ast.Statements{float64(random(0, 1000)), float64(random(1, 10))}},
+			Param: ast.ExprStatements{Statements: ast.Statements{float64(c.random(0, 1000)), float64(c.random(1, 10))}},
 		},
 		{
 			Name:  "ПобитовыйСдвигВправо",
-			Param: ast.ExprStatements{Statements: ast.Statements{float64(random(0, 1000)), float64(random(1, 10))}},
+			Param: ast.ExprStatements{Statements: ast.Statements{float64(c.random(0, 1000)), float64(c.random(1, 10))}},
 		},
 		{
 			Name:  "ПобитовоеИ",
-			Param: ast.ExprStatements{Statements: ast.Statements{float64(random(0, 1000)), float64(random(1, 10))}},
+			Param: ast.ExprStatements{Statements: ast.Statements{float64(c.random(0, 1000)), float64(c.random(1, 10))}},
 		},
-	}
+	}...)
 
-	return pool[random(0, len(pool))]
+	return pool[c.random(0, len(pool))]
 }
 
 func (c *Obfuscator) randomString(lenStr int) (result string) {
@@ -455,16 +659,27 @@ func (c *Obfuscator) randomString(lenStr int) (result string) {
 	builder := strings.Builder{}
 
 	for builder.Len() < lenStr {
-		builder.WriteString(string(charset[random(0, len(charset))]))
+		builder.WriteString(string(charset[c.random(0, len(charset))]))
 	}
 
 	return builder.String()
 }
 
 func (c *Obfuscator) obfuscateString(str string, key int32) string {
+	bitwise := c.conf.Target.bitwise()
+
 	var decrypted []rune
-	for _, c := range strings.ReplaceAll(str, "|", " ") {
-		decrypted = append(decrypted, c^key)
+	for _, r := range strings.ReplaceAll(str, "|", " ") {
+		decrypted = append(decrypted, ast.IF(bitwise, r^key, r+key))
+	}
+
+	if !c.conf.Target.binaryStrings() {
+		var builder strings.Builder
+		for _, r := range decrypted {
+			code := strconv.Itoa(int(r))
+			builder.WriteString(strings.Repeat("0", codeDigits-len(code)) + code)
+		}
+		return builder.String()
 	}
 
 	b := []byte(string(decrypted))
@@ -474,15 +689,25 @@ func (c *Obfuscator) obfuscateString(str string, key int32) string {
 }
 
 func (c *Obfuscator) newDecodeStringFunc(directive string) string {
-	strParam := c.randomString(10)
-	keyParam := c.randomString(10)
-	returnName := c.randomString(10)
-	funcName := c.randomString(30)
-
-	f := &ast.FunctionOrProcedure{
-		Type: ast.PFTypeFunction,
-		Name: funcName,
-		Body: ast.Statements{
+	strParam := c.randomString(c.intensity.Names.Variable)
+	keyParam := c.randomString(c.intensity.Names.Variable)
+	returnName := c.randomString(c.intensity.Names.Variable)
+	funcName := c.randomString(c.intensity.Names.Function)
+
+	// строка раскодируется из Base64 или, если ПолучитьСтрокуИзДвоичныхДанных недоступна,
+	// символы читаются как числа фиксированной длины (obfuscateString)
+	var prologue ast.Statements
+	var code ast.Statement
+	var to ast.Statement = ast.MethodStatement{
+		Name: "СтрДлина",
+		Param: ast.ExprStatements{Statements: ast.Statements{
+			ast.VarStatement{
+				Name: strParam,
+			},
+		}},
+	}
+	if c.conf.Target.binaryStrings() {
+		prologue = ast.Statements{
 			&ast.ExpStatement{
 				Operation: ast.OpEq,
 				Left: ast.VarStatement{
@@ -498,103 +723,101 @@ func (c *Obfuscator) newDecodeStringFunc(directive string) string {
 									Name: strParam,
 								},
 							}},
-						}, 4),
+						}),
 					}},
 				},
 			},
-			&ast.ExpStatement{
-				Operation: ast.OpEq,
-				Left: ast.VarStatement{
-					Name: returnName,
+		}
+		code = c.hideValue(ast.MethodStatement{
+			Name: "КодСимвола",
+			Param: ast.ExprStatements{Statements: ast.Statements{
+				ast.VarStatement{
+					Name: strParam,
 				},
-				Right: c.hideValue("", 4),
-			},
-			&ast.LoopStatement{
-				Body: ast.Statements{
-					&ast.ExpStatement{
-						Operation: ast.OpEq,
-						Left: ast.VarStatement{
-							Name: "код",
-						},
-						Right: c.hideValue(ast.MethodStatement{
-							Name: "КодСимвола",
-							Param: ast.ExprStatements{Statements: ast.Statements{
-								ast.VarStatement{
-									Name: strParam,
-								},
-								ast.VarStatement{
-									Name: "_",
-								},
-							}},
-						}, 4),
-					},
-					&ast.ExpStatement{
-						Operation: ast.OpEq,
-						Left: ast.VarStatement{
-							Name: returnName,
-						},
-						Right: c.hideValue(&ast.ExpStatement{
-							Operation: ast.OpPlus,
-							Left: ast.VarStatement{
-								Name: returnName,
-							},
-							Right: ast.MethodStatement{
-								Name: "Символ",
-								Param: ast.ExprStatements{Statements: ast.Statements{
-									c.hideValue(ast.MethodStatement{
-										Name: "ПобитовоеИли",
-										Param: ast.ExprStatements{Statements: ast.Statements{
-											c.hideValue(ast.MethodStatement{
-												Name: "ПобитовоеИНе",
-												Param: ast.ExprStatements{Statements: ast.Statements{
-													ast.VarStatement{
-														Name: "код",
-													},
-													ast.VarStatement{
-														Name: keyParam,
-													},
-												}},
-											}, 4),
-											c.hideValue(ast.MethodStatement{
-												Name: "ПобитовоеИНе",
-												Param: ast.ExprStatements{Statements: ast.Statements{
-													ast.VarStatement{
-														Name: keyParam,
-													},
-													c.hideValue(ast.VarStatement{
-														Name: "код",
-													}, 5),
-												}},
-											}, 4),
-										}},
-									}, 7),
-								}},
-							},
-						}, 8),
-					},
+				ast.VarStatement{
+					Name: "_",
 				},
-				To: ast.MethodStatement{
-					Name: "СтрДлина",
+			}},
+		})
+	} else {
+		to = &ast.ExpStatement{Operation: ast.OpDiv, Left: to, Right: float64(codeDigits)}
+		code = c.hideValue(ast.MethodStatement{
+			Name: "Число",
+			Param: ast.ExprStatements{Statements: ast.Statements{
+				ast.MethodStatement{
+					Name: "Сред",
 					Param: ast.ExprStatements{Statements: ast.Statements{
 						ast.VarStatement{
 							Name: strParam,
 						},
+						&ast.ExpStatement{
+							Operation: ast.OpMinus,
+							Left:      &ast.ExpStatement{Operation: ast.OpMul, Left: ast.VarStatement{Name: "_"}, Right: float64(codeDigits)},
+							Right:     float64(codeDigits - 1),
+						},
+						float64(codeDigits),
 					}},
 				},
-				For: &ast.ExpStatement{
-					Operation: ast.OpEq,
+			}},
+		})
+	}
+
+	loop := &ast.LoopStatement{
+		Body: ast.Statements{
+			&ast.ExpStatement{
+				Operation: ast.OpEq,
+				Left: ast.VarStatement{
+					Name: "код",
+				},
+				Right: code,
+			},
+			&ast.ExpStatement{
+				Operation: ast.OpEq,
+				Left: ast.VarStatement{
+					Name: returnName,
+				},
+				Right: c.hideValue(&ast.ExpStatement{
+					Operation: ast.OpPlus,
 					Left: ast.VarStatement{
-						Name: "_",
+						Name: returnName,
+					},
+					Right: ast.MethodStatement{
+						Name: "Символ",
+						Param: ast.ExprStatements{Statements: ast.Statements{
+							c.decodeChar(ast.VarStatement{Name: "код"}, ast.VarStatement{Name: keyParam}),
+						}},
 					},
-					Right: 1.000000,
+				}),
+			},
+		},
+		To: to,
+		For: &ast.ExpStatement{
+			Operation: ast.OpEq,
+			Left: ast.VarStatement{
+				Name: "_",
+			},
+			Right: 1.000000,
+		},
+	}
+
+	f := &ast.FunctionOrProcedure{
+		Type: ast.PFTypeFunction,
+		Name: funcName,
+		Body: append(prologue,
+			&ast.ExpStatement{
+				Operation: ast.OpEq,
+				Left: ast.VarStatement{
+					Name: returnName,
 				},
+				Right: c.hideValue(""),
 			},
+			loop,
 			&ast.ReturnStatement{
 				Param: ast.VarStatement{
 					Name: returnName,
 				},
 			},
-		},
+		),
 		Params: []ast.ParamStatement{
 			{Name: strParam},
 			{Name: keyParam},
@@ -603,22 +826,51 @@ func (c *Obfuscator) newDecodeStringFunc(directive string) string {
 	}
 
 	c.appendGarbage(&f.Body)
-	c.appendGarbage(&f.Body[2].(*ast.LoopStatement).Body)
+	c.appendGarbage(&loop.Body)
+
+	c.replaceLoopToGoto(&f.Body, loop)
 
-	c.replaceLoopToGoto(&f.Body, f.Body[2].(*ast.LoopStatement), true)
+	c.addFunction(f)
+	// декодер общий для всех методов и не зависит от контекста, поэтому объявляется вне условий препроцессора
+	delete(c.placement, f)
 
-	c.a.ModuleStatement.Body = append(c.a.ModuleStatement.Body, f)
 	return funcName
 }
 
+// genErrors ошибки генератора условий, который работает в своих горутинах. Забираются после каждого запуска
+type genErrors struct {
+	mx    sync.Mutex
+	items []error
+}
+
+func (e *genErrors) add(err error) {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+
+	e.items = append(e.items, err)
+}
+
+// reset возвращает накопленные ошибки и очищает список
+func (e *genErrors) reset() []error {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+
+	items := e.items
+	e.items = nil
+	return items
+}
+
 func (c *Obfuscator) genCondition() {
+	predicates := c.intensity.Predicates
+
 	expression := func(op string) (string, bool) {
-		left := c.randomMathExp(int(random(2, 7)))
-		right := c.randomMathExp(int(random(2, 7)))
+		left := c.randomMathExp(c.depth(predicates))
+		right := c.randomMathExp(c.depth(predicates))
 
 		expression, err := govaluate.NewEvaluableExpression(left + op + right)
 		if err != nil {
-			fmt.Println(errors.Wrap(err, "genCondition error"))
+			// диагностику добавит ObfuscateResult, здесь другая горутина
+			c.genErrors.add(errors.Wrap(err, "genCondition error"))
 			return "", false
 		}
 
@@ -632,6 +884,16 @@ func (c *Obfuscator) genCondition() {
 		return "", false
 	}
 
+	// send ждет места в канале или отмены контекста, чтобы генератор не зависал после завершения обфускации
+	send := func(ch chan string, exp string) bool {
+		select {
+		case ch <- exp:
+			return true
+		case <-c.ctx.Done():
+			return false
+		}
+	}
+
 	// true
 	go func() {
 		defer close(c.trueCondition)
@@ -641,11 +903,11 @@ func (c *Obfuscator) genCondition() {
 			case <-c.ctx.Done():
 				return
 			default:
-				if exp, ok := expression(">"); ok {
-					c.trueCondition <- exp
+				if exp, ok := expression(">"); ok && !send(c.trueCondition, exp) {
+					return
 				}
-				if exp, ok := expression("<"); ok {
-					c.trueCondition <- exp
+				if exp, ok := expression("<"); ok && !send(c.trueCondition, exp) {
+					return
 				}
 			}
 		}
@@ -660,11 +922,11 @@ func (c *Obfuscator) genCondition() {
 			case <-c.ctx.Done():
 				return
 			default:
-				if exp, ok := expression(">"); !ok && exp != "" {
-					c.falseCondition <- exp
+				if exp, ok := expression(">"); !ok && exp != "" && !send(c.falseCondition, exp) {
+					return
 				}
-				if exp, ok := expression("<"); !ok && exp != "" {
-					c.falseCondition <- exp
+				if exp, ok := expression("<"); !ok && exp != "" && !send(c.falseCondition, exp) {
+					return
 				}
 			}
 		}
@@ -678,9 +940,9 @@ func (c *Obfuscator) randomMathExp(lenExp int) (result string) {
 	operations := []string{"-", "+", "/", "*"}
 
 	for i := 0; i < lenExp; i++ {
-		builder.WriteString(strconv.Itoa(int(random(1, 1000))))
+		builder.WriteString(strconv.Itoa(int(c.random(1, 1000))))
 		if i < lenExp-1 {
-			builder.WriteString(operations[random(0, len(operations))])
+			builder.WriteString(operations[c.random(0, len(operations))])
 		}
 	}
 
@@ -690,118 +952,13 @@ func (c *Obfuscator) randomMathExp(lenExp int) (result string) {
 func (c *Obfuscator) convStrExpToExpStatement(str string) *ast.ExpStatement {
 	astObj := ast.NewAST(str)
 	if err := astObj.Parse(); err != nil {
-		fmt.Println(errors.Wrap(err, "ast parse error"))
+		c.report(SeverityError, DiagConditionParse, c.current, "condition %q does not parse: %v", str, err)
 		return new(ast.ExpStatement)
 	}
 
 	return astObj.ModuleStatement.Body[0].(*ast.ExpStatement)
 }
 
-func (c *Obfuscator) loopToGoto(loop *ast.LoopStatement) ast.Statements {
-	start := &ast.GoToLabelStatement{Name: c.randomString(5)}
-	end := &ast.GoToLabelStatement{Name: c.randomString(5)}
-
-	// цикл Пока
-	if loop.WhileExpr != nil {
-		newBody := ast.Statements{
-			start,
-			&ast.IfStatement{
-				Expression: c.invertExp(loop.WhileExpr),
-				TrueBlock:  ast.Statements{ast.GoToStatement{Label: end}},
-			},
-		}
-
-		// меняем прервать и продолжить
-		ast.StatementWalk(loop, loop.Body, func(root *ast.FunctionOrProcedure, parentStm, stm *ast.Statement) {
-			switch (*stm).(type) {
-			case ast.ContinueStatement:
-				*stm = ast.GoToStatement{Label: start}
-			case ast.BreakStatement:
-				*stm = ast.GoToStatement{Label: end}
-			}
-		})
-
-		newBody = append(append(newBody, loop.Body...), ast.GoToStatement{Label: start}, end)
-		return newBody
-	}
-
-	// цикл Для а = 0 По n Цикл
-	if loop.To != nil {
-		exp, ok := loop.For.(*ast.ExpStatement)
-		if !ok {
-			return ast.Statements{loop}
-		}
-
-		newBody := ast.Statements{
-			exp,
-			start,
-			&ast.IfStatement{
-				Expression: &ast.ExpStatement{
-					Operation: ast.OpGt,
-					Left:      exp.Left,
-					Right:     loop.To,
-				},
-				TrueBlock: ast.Statements{ast.GoToStatement{Label: end}},
-			},
-		}
-
-		newBody = append(append(newBody, loop.Body...),
-			&ast.ExpStatement{
-				Operation: ast.OpEq,
-				Left:      exp.Left,
-				Right: &ast.ExpStatement{
-					Operation: ast.OpPlus,
-					Left:      exp.Left,
-					Right:     float64(1),
-				},
-			},
-			ast.GoToStatement{Label: start},
-			end)
-		return newBody
-	}
-
-	return ast.Statements{loop}
-}
-
-func (c *Obfuscator) invertExp(exp ast.Statement) ast.Statement {
-	switch v := exp.(type) {
-	case ast.INot:
-		return v.Not()
-	case bool:
-		return !v
-	default:
-		return exp
-	}
-}
-
-func (c *Obfuscator) replaceLoopToGoto(body *ast.Statements, loop *ast.LoopStatement, force bool) {
-	if c.conf.RepLoopByGoto || force {
-		newStatements := c.loopToGoto(loop)
-		for i := len(*body) - 1; i >= 0; i-- {
-			if (*body)[i] == loop {
-				*body = append(append(append(ast.Statements{}, (*body)[:i]...), newStatements...), (*body)[i+1:]...)
-			}
-		}
-	}
-}
-
-func (c *Obfuscator) replaceAllLoopToGoto(body *ast.Statements) {
-	for i := len(*body) - 1; i >= 0; i-- {
-		var newStatements ast.Statements
-
-		switch v := (*body)[i].(type) {
-		case *ast.LoopStatement:
-			newStatements = c.loopToGoto(v)
-		case ast.LoopStatement:
-			newStatements = c.loopToGoto(&v)
-		}
-
-		if newStatements != nil {
-			*body = append(append(append(ast.Statements{}, (*body)[:i]...), newStatements...), (*body)[i+1:]...)
-		}
-	}
-}
-
 func (c *Obfuscator) shuffleExpressions(body ast.Statements) []ast.Statement {
 	// if !c.conf.ShuffleExpressions {
 	// 	return body
@@ -810,11 +967,11 @@ func (c *Obfuscator) shuffleExpressions(body ast.Statements) []ast.Statement {
 	orderMap := make(map[int]string, len(body))
 	expr := make(map[int]ast.Statement, len(body))
 	for i, item := range body {
-		orderMap[i] = c.randomString(10)
+		orderMap[i] = c.newLabel().Name
 		expr[i] = item
 	}
 
-	orderMap[len(body)] = c.randomString(10)
+	orderMap[len(body)] = c.newLabel().Name
 
 	newBody := make([]ast.Statement, 0, len(body))
 	start := &ast.GoToLabelStatement{Name: orderMap[0]}
@@ -830,8 +987,8 @@ func (c *Obfuscator) shuffleExpressions(body ast.Statements) []ast.Statement {
 	return newBody
 }
 
-// [min, max)
-func random(min, max int) int64 {
+// random [min, max)
+func (c *Obfuscator) random(min, max int) int64 {
 	max -= min
 	if max <= 0 {
 		return 0
@@ -839,8 +996,9 @@ func random(min, max int) int64 {
 
 	randomNumber, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
 	if err != nil {
-		fmt.Println(errors.Wrap(err, "rand error"))
-		return 0
+		// ObfuscateResult сообщит об этом предупреждением
+		c.randomFailures.Add(1)
+		return mathrand.Int64N(int64(max)) + int64(min)
 	}
 
 	return randomNumber.Int64() + int64(min)
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -1122,7 +1124,8 @@ func TestObfuscateExp(t *testing.T) {
 }
 
 func TestGenCondition(t *testing.T) {
-	ctx, _ := context.WithTimeout(context.Background(), time.Millisecond*500)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
+	defer cancel()
 	obf := NewObfuscatory(ctx, Config{})
 
 	for c := range obf.falseCondition {
@@ -1156,3 +1159,80 @@ func compareHashes(str1, str2 string) bool {
 
 	return hash1 == hash2
 }
+
+func TestPresets(t *testing.T) {
+	for _, p := range Presets() {
+		conf, err := p.Config()
+		if assert.NoError(t, err, p) {
+			assert.NotEmpty(t, conf.defaultPasses(), p)
+		}
+	}
+
+	conf, err := Preset("Paranoid").Config()
+	if assert.NoError(t, err) {
+		assert.True(t, conf.RepExpByEval)
+		assert.True(t, conf.CallStackHell)
+	}
+
+	_, err = Preset("unknown").Config()
+	assert.Error(t, err)
+}
+
+var functionDecl = regexp.MustCompile(`(?i)функция\s+([\p{L}\d_]+)\s*\(`)
+
+func TestIntensity(t *testing.T) {
+	i := Intensity{Ternary: Level{MinDepth: 5, MaxDepth: 3}, Garbage: Level{Probability: ptr(0.1)}}.withDefaults()
+	assert.Equal(t, 5, i.Ternary.MaxDepth)
+	assert.Equal(t, 0.1, i.Garbage.probability())
+
+	// нулевые вероятность и вложенность можно задать явно
+	i = DefaultIntensity().merge(Intensity{Garbage: Level{Probability: ptr(0.0), MaxNesting: ptr(0)}}).withDefaults()
+	assert.Equal(t, 0.0, i.Garbage.probability())
+	assert.Equal(t, 0, i.Garbage.nesting())
+	assert.Equal(t, DefaultIntensity().CallStack, i.CallStack)
+	assert.Equal(t, DefaultIntensity().Names, i.Names)
+
+	for n := 0; n < 100; n++ {
+		d := new(Obfuscator).depth(DefaultIntensity().CallStack)
+		assert.True(t, d >= 3 && d < 7, d)
+	}
+
+	code := `&НаСервереБезКонтекста
+			Функция Команда1НаСервере()
+				Сообщить("тест");
+			 КонецФункции`
+
+	obf := NewObfuscatory(context.Background(), Config{
+		HideString: true,
+		Intensity:  Intensity{Names: NameLength{Function: 50}},
+	})
+	obCode, err := obf.Obfuscate(code)
+	if assert.NoError(t, err) {
+		// единственная функция, кроме исходной, - декодер строк
+		var generated []string
+		for _, m := range functionDecl.FindAllStringSubmatch(obCode, -1) {
+			if m[1] != "Команда1НаСервере" {
+				generated = append(generated, m[1])
+			}
+		}
+		if assert.Len(t, generated, 1) {
+			assert.Len(t, generated[0], 50)
+		}
+		assert.NotContains(t, obCode, `"тест"`)
+	}
+}
+
+func TestGenConditionStops(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	NewObfuscatory(ctx, Config{})
+	// генераторы успевают заполнить буферы каналов и ждут, пока условия заберут
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
@@ -0,0 +1,195 @@
+package obfuscator
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+)
+
+// passCost условная стоимость выполнения одного преобразования прохода при каждом вызове кода, для оценки накладных расходов.
+// Проходы, которых нет в списке (переименование, Перейти, области), на скорость выполнения не влияют
+var passCost = map[string]int{
+	PassStrings:    50,  // декодирование строки из Base64 с обходом по символам
+	PassEval:       100, // компиляция кода в Выполнить()/Вычислить()
+	PassExceptions: 50,  // ВызватьИсключение и Попытка
+	PassCallStack:  30,  // цепочка вызовов фейковых функций
+	PassConditions: 5,   // вычисление фиктивных условий
+	PassTernary:    3,   // вычисление тернарных операторов
+	PassGarbage:    1,   // мусорные присваивания
+}
+
+// loopFactor во сколько раз условно дороже преобразование внутри цикла
+const loopFactor = 10
+
+// costly замедляет ли проход выполнение кода
+func costly(pass string) bool {
+	return passCost[pass] > 0
+}
+
+// ProcedureOverhead оценка накладных расходов обфускации метода
+type ProcedureOverhead struct {
+	Path      string `json:"path,omitempty"`
+	Procedure string `json:"procedure"`
+
+	// Hot метод помечен как нагруженный (аннотация obfuscate:hot или Config.HotMethods)
+	Hot bool `json:"hot,omitempty"`
+
+	// Transforms количество преобразований с затратами при выполнении, InLoops - сколько из них внутри циклов
+	Transforms map[string]int `json:"transforms"`
+	InLoops    int            `json:"inLoops,omitempty"`
+
+	// Cost условная стоимость: сумма passCost преобразований, внутри циклов стоимость умножается на 10
+	Cost int `json:"cost"`
+}
+
+// procStats преобразования одного метода
+type procStats struct {
+	transforms map[string]int
+	inLoops    int
+	loopCost   int
+}
+
+// countProcedure учитывает преобразование текущего метода для оценки накладных расходов
+func (c *Obfuscator) countProcedure(pass string) {
+	if !costly(pass) || c.current == nil {
+		return
+	}
+
+	s, ok := c.procStats[c.current]
+	if !ok {
+		s = &procStats{transforms: map[string]int{}}
+		c.procStats[c.current] = s
+	}
+
+	s.transforms[pass]++
+	if c.hot {
+		s.inLoops++
+		s.loopCost += passCost[pass] * (loopFactor - 1)
+	}
+}
+
+// overhead оценка накладных расходов по методам, от самых затратных
+func (c *Obfuscator) overhead(path string) []ProcedureOverhead {
+	var result []ProcedureOverhead
+	for f, s := range c.procStats {
+		o := ProcedureOverhead{
+			Path:       path,
+			Hot:        c.methodSettings(f).hot,
+			Transforms: s.transforms,
+			InLoops:    s.inLoops,
+			Cost:       s.loopCost,
+		}
+		if f.Name != "" {
+			_, o.Procedure = procedureDeclaration(c.source, c.sourceName(f.Name))
+		}
+		for pass, n := range s.transforms {
+			o.Cost += passCost[pass] * n
+		}
+
+		result = append(result, o)
+	}
+
+	sortOverhead(result)
+	return result
+}
+
+func sortOverhead(items []ProcedureOverhead) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Cost != items[j].Cost {
+			return items[i].Cost > items[j].Cost
+		}
+		if items[i].Path != items[j].Path {
+			return items[i].Path < items[j].Path
+		}
+		return items[i].Procedure < items[j].Procedure
+	})
+}
+
+// isHotMethod помечен ли метод как нагруженный в Config.HotMethods
+func (c *Obfuscator) isHotMethod(f *ast.FunctionOrProcedure) bool {
+	name := c.sourceName(f.Name)
+	for _, hot := range c.conf.HotMethods {
+		if strings.EqualFold(hot, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// enterHot помечает код, который выполняется в цикле, пока не будет вызвана возвращенная функция
+func (c *Obfuscator) enterHot(parent, item *ast.Statement) func() {
+	if c.hot || !c.inLoop(parent) && !c.inLoop(item) {
+		return func() {}
+	}
+
+	c.hot = true
+	return func() { c.hot = false }
+}
+
+func (c *Obfuscator) inLoop(stm *ast.Statement) bool {
+	if stm == nil {
+		return false
+	}
+
+	_, ok := c.loopItems[stm]
+	return ok
+}
+
+// collectLoopItems собирает адреса конструкций, которые выполняются на каждой итерации цикла: тело и условие Пока,
+// а также код между метками цикла, замененного на Перейти. Адреса собираются перед каждым проходом, так как предыдущие проходы меняют дерево
+func (c *Obfuscator) collectLoopItems(module *ast.ModuleStatement) map[*ast.Statement]struct{} {
+	items := map[*ast.Statement]struct{}{}
+	c.collectLoopValue(reflect.ValueOf(module.Body), false, items)
+	return items
+}
+
+func (c *Obfuscator) collectLoopValue(v reflect.Value, inLoop bool, items map[*ast.Statement]struct{}) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		if loop, ok := v.Interface().(*ast.LoopStatement); ok {
+			c.collectLoopValue(reflect.ValueOf(&loop.For).Elem(), inLoop, items)
+			c.collectLoopValue(reflect.ValueOf(&loop.To).Elem(), inLoop, items)
+			c.collectLoopValue(reflect.ValueOf(&loop.In).Elem(), inLoop, items)
+			c.collectLoopValue(reflect.ValueOf(&loop.WhileExpr).Elem(), true, items)
+			c.collectLoopValue(reflect.ValueOf(loop.Body), true, items)
+			return
+		}
+		c.collectLoopValue(v.Elem(), inLoop, items)
+	case reflect.Interface:
+		if inLoop && v.CanAddr() && v.Type() == statementType {
+			items[v.Addr().Interface().(*ast.Statement)] = struct{}{}
+		}
+		if !v.IsNil() {
+			c.collectLoopValue(v.Elem(), inLoop, items)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				c.collectLoopValue(v.Field(i), inLoop, items)
+			}
+		}
+	case reflect.Slice:
+		// ends метки концов циклов, замененных на Перейти, внутри которых находится текущий элемент
+		var ends []*ast.GoToLabelStatement
+		for i := 0; i < v.Len(); i++ {
+			label, _ := v.Index(i).Interface().(*ast.GoToLabelStatement)
+			if len(ends) > 0 && label == ends[len(ends)-1] {
+				ends = ends[:len(ends)-1]
+			}
+
+			c.collectLoopValue(v.Index(i), inLoop || len(ends) > 0, items)
+
+			if end, ok := c.loopLabels[label]; ok && label != nil {
+				ends = append(ends, end)
+			}
+		}
+	}
+}
+
+var statementType = reflect.TypeOf((*ast.Statement)(nil)).Elem()
@@ -0,0 +1,93 @@
+package obfuscator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectLoopItems(t *testing.T) {
+	exp := &ast.ExpStatement{Operation: ast.OpEq, Left: ast.VarStatement{Name: "А"}, Right: "строка"}
+	loop := &ast.LoopStatement{
+		WhileExpr: &ast.ExpStatement{Operation: ast.OpGt, Left: ast.VarStatement{Name: "А"}, Right: float64(1)},
+		Body:      ast.Statements{exp, ast.MethodStatement{Name: "Сообщить", Param: ast.ExprStatements{Statements: ast.Statements{"х"}}}},
+	}
+	top := &ast.ExpStatement{Operation: ast.OpEq, Left: ast.VarStatement{Name: "Б"}, Right: "вне"}
+	f := &ast.FunctionOrProcedure{Name: "Тест", Body: ast.Statements{top, loop}}
+
+	obf := NewObfuscatory(context.Background(), Config{})
+	obf.loopLabels = map[*ast.GoToLabelStatement]*ast.GoToLabelStatement{}
+	items := obf.collectLoopItems(&ast.ModuleStatement{Body: ast.Statements{f}})
+	assert.Contains(t, items, &loop.Body[0])
+	assert.Contains(t, items, &exp.Right)
+	assert.Contains(t, items, &loop.WhileExpr)
+	assert.Contains(t, items, &loop.Body[1].(ast.MethodStatement).Param.Statements[0])
+	assert.NotContains(t, items, &top.Right)
+	assert.NotContains(t, items, &f.Body[1])
+
+	// цикл, замененный на Перейти
+	start, end := &ast.GoToLabelStatement{Name: "start"}, &ast.GoToLabelStatement{Name: "end"}
+	body := ast.Statements{start, "в цикле", ast.GoToStatement{Label: start}, end, "после"}
+	obf.loopLabels[start] = end
+
+	items = obf.collectLoopItems(&ast.ModuleStatement{Body: ast.Statements{&ast.FunctionOrProcedure{Name: "Тест", Body: body}}})
+	assert.Contains(t, items, &body[1])
+	assert.NotContains(t, items, &body[3])
+	assert.NotContains(t, items, &body[4])
+}
+
+func TestPerformance(t *testing.T) {
+	code := `Процедура Тест(Коллекция)
+	Сообщить("снаружи");
+	Элементы = Новый Массив;
+	Элементы.Добавить(Коллекция);
+	Для Каждого Элемент Из Элементы Цикл
+		Сообщить("внутри");
+	КонецЦикла;
+КонецПроцедуры
+
+// obfuscate:hot
+Процедура Горячая()
+	Сообщить("горячая");
+КонецПроцедуры
+
+Процедура ИзСписка()
+	Сообщить("из списка");
+КонецПроцедуры`
+
+	conf := Config{HideString: true, HotMethods: []string{"изсписка"}}
+
+	result, err := NewObfuscatory(context.Background(), conf).ObfuscateResult(code, ModuleInfo{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NotContains(t, result.Code, `"снаружи"`)
+	assert.NotContains(t, result.Code, `"внутри"`)
+	assert.Contains(t, result.Code, `"горячая"`)
+	assert.Contains(t, result.Code, `"из списка"`)
+	if assert.Len(t, result.Report.Overhead, 1) {
+		assert.Equal(t, ProcedureOverhead{
+			Procedure:  "Тест",
+			Transforms: map[string]int{PassStrings: 2},
+			InLoops:    1,
+			Cost:       2*passCost[PassStrings] + passCost[PassStrings]*(loopFactor-1),
+		}, result.Report.Overhead[0])
+	}
+
+	conf.Performance = true
+	conf.RepLoopByGoto = true
+	result, err = NewObfuscatory(context.Background(), conf).ObfuscateResult(code, ModuleInfo{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NotContains(t, result.Code, `"снаружи"`)
+	assert.Contains(t, result.Code, `"внутри"`)
+	assert.NotContains(t, result.Code, "Для Каждого")
+	if assert.Len(t, result.Report.Overhead, 1) {
+		assert.Equal(t, 0, result.Report.Overhead[0].InLoops)
+	}
+}
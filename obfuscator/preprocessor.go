@@ -0,0 +1,568 @@
+package obfuscator
+
+import (
+	mathrand "math/rand/v2"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+	"github.com/pkg/errors"
+)
+
+// RegionsMode что делать с областями (#Область) в результате
+type RegionsMode string
+
+const (
+	// RegionsDefault области выводятся как раньше
+	RegionsDefault RegionsMode = ""
+	// RegionsKeep области исходного модуля сохраняются вокруг своих методов
+	RegionsKeep RegionsMode = "keep"
+	// RegionsRemove области удаляются
+	RegionsRemove RegionsMode = "remove"
+	// RegionsDecoy области исходного модуля удаляются, методы группируются в области с ложными названиями
+	RegionsDecoy RegionsMode = "decoy"
+)
+
+var (
+	ifRe         = regexp.MustCompile(`(?i)^\s*#(?:если|if)\s+(.+?)\s+(?:тогда|then)\s*$`)
+	elseIfRe     = regexp.MustCompile(`(?i)^\s*#(?:иначеесли|elsif)\s+(.+?)\s+(?:тогда|then)\s*$`)
+	elseRe       = regexp.MustCompile(`(?i)^\s*#(?:иначе|else)\s*$`)
+	endIfRe      = regexp.MustCompile(`(?i)^\s*#(?:конецесли|endif)\s*$`)
+	regionNameRe = regexp.MustCompile(`(?i)^\s*#(?:область|region)\s+([\p{L}\p{N}_]+)`)
+	endMethodRe  = regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}_])(?:конецпроцедуры|конецфункции|endprocedure|endfunction)\s*;?\s*(?://.*)?$`)
+	varRe        = regexp.MustCompile(`(?i)^\s*(?:перем|var)\s+([^;]+);`)
+	exportRe     = regexp.MustCompile(`(?i)\s+(?:экспорт|export)\s*$`)
+)
+
+// названия ложных областей
+var decoyRegions = []string{
+	"ПрограммныйИнтерфейс",
+	"СлужебныйПрограммныйИнтерфейс",
+	"СлужебныеПроцедурыИФункции",
+	"ОбработчикиСобытий",
+	"УстаревшиеПроцедурыИФункции",
+	"ОбновлениеИнформационнойБазы",
+	"ПроверкаЛицензии",
+	"Криптография",
+	"ОбработчикиКоманд",
+	"ИнтеграцияСВнешнимиСистемами",
+}
+
+func (m RegionsMode) validate() error {
+	switch m {
+	case RegionsDefault, RegionsKeep, RegionsRemove, RegionsDecoy:
+		return nil
+	}
+
+	return errors.Errorf("unknown regions mode %q", m)
+}
+
+// preBlock область или условие препроцессора, внутри которого находится метод
+type preBlock struct {
+	region bool
+	// text имя области или условие
+	text string
+}
+
+type preCondition struct {
+	// branches условия предыдущих ветвей
+	branches []string
+	current  string
+	isElse   bool
+}
+
+// expr условие текущей ветви с учетом предыдущих (#Иначе превращается в Не (...))
+func (p *preCondition) expr() string {
+	var parts []string
+	for _, b := range p.branches {
+		parts = append(parts, "Не ("+b+")")
+	}
+	if !p.isElse {
+		parts = append(parts, ast.IF(len(parts) == 0, p.current, "("+p.current+")"))
+	}
+
+	return strings.Join(parts, " И ")
+}
+
+// preprocessor области и условия препроцессора исходного модуля
+type preprocessor struct {
+	// methods пути методов в порядке следования, имя в нижнем регистре
+	methods []preItem
+	// variables пути переменных модуля в порядке следования
+	variables []preItem
+	// conditional в модуле есть условная компиляция
+	conditional bool
+	// controlled методы с &ИзменениеИКонтроль и асинхронные методы с Ждать, выводятся без изменений
+	controlled []preItem
+	// extension в модуле есть аннотации расширения
+	extension bool
+	// async в модуле есть асинхронные методы
+	async bool
+	// source текст для разбора: без аннотаций расширения, Асинх и методов, которые выводятся без изменений
+	source string
+	// markers метки, которыми в тексте для разбора заменены инструкции препроцессора внутри методов
+	// и отмечено начало кода основной программы в других условиях
+	markers map[string]preMarker
+}
+
+// preMarker инструкция препроцессора, которая проходит через разбор и обфускацию в виде метки
+type preMarker struct {
+	// line исходная строка #Если/#ИначеЕсли/#Иначе/#КонецЕсли внутри метода, восстанавливается при выводе
+	line string
+	// main метка кода основной программы, path - условия, в которых находится код после метки
+	main bool
+	path []preBlock
+}
+
+type preItem struct {
+	name string
+	path []preBlock
+	// annotations аннотации расширения метода (&Перед("...") и т.п.)
+	annotations []string
+	// text исходный текст метода, который выводится без изменений
+	text string
+	// async метод объявлен как Асинх
+	async bool
+	// await в асинхронном методе есть Ждать
+	await bool
+}
+
+// parsePreprocessor разбирает инструкции препроцессора вне методов. В дереве разбора инструкций препроцессора нет,
+// поэтому условия внутри методов заменяются метками и восстанавливаются при выводе без изменений,
+// а код основной программы в условиях отмечается метками с путем условий
+func parsePreprocessor(code string) (*preprocessor, error) {
+	result := &preprocessor{markers: map[string]preMarker{}}
+
+	var stack []interface{} // string - область, *preCondition - условие
+	var method string
+	var pending []int     // строки директив и аннотаций перед методом
+	controlledStart := -1 // первая строка метода с &ИзменениеИКонтроль
+	depth := 0            // вложенность условий внутри метода
+	var mainPath []preBlock
+
+	path := func() []preBlock {
+		var p []preBlock
+		for _, item := range stack {
+			switch v := item.(type) {
+			case string:
+				p = append(p, preBlock{region: true, text: v})
+			case *preCondition:
+				p = append(p, preBlock{text: v.expr()})
+			}
+		}
+		return p
+	}
+	top := func() (*preCondition, bool) {
+		if len(stack) == 0 {
+			return nil, false
+		}
+		c, ok := stack[len(stack)-1].(*preCondition)
+		return c, ok
+	}
+
+	lines := strings.Split(code, "\n")
+	endControlled := func(i int) {
+		if controlledStart < 0 {
+			return
+		}
+
+		result.controlled[len(result.controlled)-1].text = strings.Join(lines[controlledStart:i+1], "\n")
+		for k := controlledStart; k <= i; k++ {
+			lines[k] = ""
+		}
+		controlledStart = -1
+	}
+
+	for i := 0; i < len(lines); i++ {
+		text, line := lines[i], i+1
+
+		if method != "" {
+			switch {
+			case controlledStart >= 0 && endMethodRe.MatchString(text):
+				endControlled(i)
+				method = ""
+			case controlledStart >= 0:
+				// #Вставка/#Удаление и исходный текст метода не меняются
+			case ifRe.MatchString(text), elseIfRe.MatchString(text), elseRe.MatchString(text), endIfRe.MatchString(text):
+				switch {
+				case ifRe.MatchString(text):
+					depth++
+				case depth == 0:
+					return nil, errors.Errorf("line %d: unexpected %q inside method %q", line, strings.TrimSpace(text), method)
+				case endIfRe.MatchString(text):
+					depth--
+				}
+				lines[i] = "~" + result.marker(preMarker{line: strings.TrimSpace(text)}) + ":"
+			case endMethodRe.MatchString(text):
+				if depth != 0 {
+					return nil, errors.Errorf("line %d: conditional compilation is not closed in method %q", line, method)
+				}
+				method = ""
+			}
+			continue
+		}
+
+		switch {
+		case ifRe.MatchString(text):
+			stack = append(stack, &preCondition{current: strings.TrimSpace(ifRe.FindStringSubmatch(text)[1])})
+			result.conditional = true
+		case elseIfRe.MatchString(text), elseRe.MatchString(text):
+			c, ok := top()
+			if !ok {
+				return nil, errors.Errorf("line %d: unexpected %q", line, strings.TrimSpace(text))
+			}
+			if !c.isElse {
+				c.branches = append(c.branches, c.current)
+			}
+			if m := elseIfRe.FindStringSubmatch(text); m != nil {
+				c.current, c.isElse = strings.TrimSpace(m[1]), false
+			} else {
+				c.current, c.isElse = "", true
+			}
+		case endIfRe.MatchString(text):
+			if _, ok := top(); !ok {
+				return nil, errors.Errorf("line %d: unexpected %q", line, strings.TrimSpace(text))
+			}
+			stack = stack[:len(stack)-1]
+		case regionRe.MatchString(text):
+			name := ""
+			if m := regionNameRe.FindStringSubmatch(text); m != nil {
+				name = m[1]
+			}
+			stack = append(stack, name)
+		case endRegionRe.MatchString(text):
+			if len(stack) == 0 {
+				return nil, errors.Errorf("line %d: unexpected %q", line, strings.TrimSpace(text))
+			}
+			if _, ok := stack[len(stack)-1].(string); !ok {
+				return nil, errors.Errorf("line %d: region is closed inside conditional compilation", line)
+			}
+			stack = stack[:len(stack)-1]
+		case methodRe.MatchString(text):
+			method = methodRe.FindStringSubmatch(text)[1]
+			item := preItem{name: strings.ToLower(method), path: path(), async: asyncRe.MatchString(text)}
+			item.await = item.async && hasAwait(lines, i)
+			result.async = result.async || item.async
+
+			var annotationLines []int
+			for _, k := range pending {
+				if extensionRe.MatchString(lines[k]) {
+					item.annotations = append(item.annotations, strings.TrimSpace(lines[k]))
+					annotationLines = append(annotationLines, k)
+				}
+			}
+			result.extension = result.extension || len(item.annotations) > 0
+
+			if isControlled(item.annotations) || item.await {
+				result.controlled = append(result.controlled, item)
+				controlledStart = i
+				if len(pending) > 0 {
+					controlledStart = pending[0]
+				}
+			} else {
+				result.methods = append(result.methods, item)
+				for _, k := range annotationLines {
+					lines[k] = ""
+				}
+				// Асинх восстанавливается при выводе модуля
+				if item.async {
+					lines[i] = asyncRe.ReplaceAllString(text, "")
+				}
+			}
+
+			pending = nil
+			if endMethodRe.MatchString(text) {
+				endControlled(i)
+				method = ""
+			}
+		case varRe.MatchString(text):
+			pending = nil
+			for _, name := range strings.Split(varRe.FindStringSubmatch(text)[1], ",") {
+				name = strings.TrimSpace(exportRe.ReplaceAllString(name, ""))
+				result.variables = append(result.variables, preItem{name: strings.ToLower(name), path: path()})
+			}
+		case directiveRe.MatchString(text):
+			pending = append(pending, i)
+		case strings.TrimSpace(text) == "", commentRe.MatchString(text), strings.HasPrefix(strings.TrimSpace(text), "#"):
+		default:
+			// код основной программы, начало кода в других условиях отмечается меткой
+			pending = nil
+			if p := conditions(path()); !samePath(p, mainPath) {
+				lines[i] = "~" + result.marker(preMarker{main: true, path: p}) + ": " + text
+				mainPath = p
+			}
+		}
+	}
+
+	if method != "" && controlledStart >= 0 {
+		return nil, errors.Errorf("method %q is not closed", method)
+	}
+
+	result.source = strings.Join(lines, "\n")
+	return result, nil
+}
+
+// marker добавляет метку со случайным именем. Имя не попадает в результат, поэтому хватает math/rand
+func (p *preprocessor) marker(m preMarker) string {
+	for {
+		var b strings.Builder
+		for b.Len() < 16 {
+			b.WriteByte(byte('a' + mathrand.IntN(26)))
+		}
+
+		if _, ok := p.markers[b.String()]; !ok {
+			p.markers[b.String()] = m
+			return b.String()
+		}
+	}
+}
+
+// mainMarker метка начала кода основной программы в других условиях
+func (p *preprocessor) mainMarker(stm ast.Statement) (preMarker, bool) {
+	name, ok := labelName(stm)
+	if !ok || p == nil {
+		return preMarker{}, false
+	}
+
+	m, ok := p.markers[name]
+	return m, ok && m.main
+}
+
+// restoreMarkers заменяет метки внутри методов исходными инструкциями препроцессора
+func (p *preprocessor) restoreMarkers(text string) string {
+	if p == nil {
+		return text
+	}
+
+	for name, m := range p.markers {
+		if !m.main {
+			re := regexp.MustCompile(`~\s*` + name + `\s*:[ \t]*`)
+			text = re.ReplaceAllLiteralString(text, "\n"+m.line+"\n")
+		}
+	}
+
+	return text
+}
+
+func samePath(a, b []preBlock) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// placePreprocessor сопоставляет методы модуля с областями и условиями, в которых они объявлены.
+// Методы сопоставляются по порядку объявления, поэтому методы с одинаковыми именами (в разных ветвях #Если)
+// получают каждый свое место
+func (c *Obfuscator) placePreprocessor(module *ast.ModuleStatement) {
+	c.placement = map[*ast.FunctionOrProcedure][]preBlock{}
+	c.extAnnotations = map[*ast.FunctionOrProcedure][]string{}
+	c.async = map[*ast.FunctionOrProcedure]bool{}
+	if c.pre == nil {
+		return
+	}
+
+	items := c.pre.methods
+	for _, stm := range module.Body {
+		f, ok := stm.(*ast.FunctionOrProcedure)
+		if !ok || len(items) == 0 {
+			continue
+		}
+
+		if item := items[0]; item.name == strings.ToLower(f.Name) {
+			c.placement[f] = item.path
+			c.extAnnotations[f] = item.annotations
+			c.async[f] = item.async
+			items = items[1:]
+		}
+	}
+}
+
+// conditions только условия препроцессора, без областей
+func conditions(path []preBlock) []preBlock {
+	var result []preBlock
+	for _, b := range path {
+		if !b.region {
+			result = append(result, b)
+		}
+	}
+
+	return result
+}
+
+// conditionContexts контекст, который следует из условия препроцессора. nil - условие не сужает контекст
+func conditionContexts(expr string) []CompilationContext {
+	words := strings.FieldsFunc(strings.ToLower(expr), func(r rune) bool {
+		return r == ' ' || r == '(' || r == ')' || r == '\t'
+	})
+
+	client, server := false, false
+	for _, w := range words {
+		switch w {
+		case "не", "not":
+			return nil
+		case "сервер", "server", "внешнеесоединение", "externalconnection", "мобильноеприложениесервер", "mobileappserver":
+			server = true
+		case "клиент", "client", "тонкийклиент", "thinclient", "вебклиент", "webclient", "мобильныйклиент", "mobileclient",
+			"толстыйклиентобычноеприложение", "thickclientordinaryapplication", "толстыйклиентуправляемоеприложение",
+			"thickclientmanagedapplication", "мобильноеприложениеклиент", "mobileappclient":
+			client = true
+		}
+	}
+
+	switch {
+	case client && !server:
+		return []CompilationContext{ContextClient}
+	case server && !client:
+		return []CompilationContext{ContextServer}
+	}
+
+	return nil
+}
+
+// customPrint нужно ли выводить модуль по частям, восстанавливая области и условия препроцессора
+func (c *Obfuscator) customPrint() bool {
+	return (c.pre != nil && (c.pre.conditional || c.pre.extension || c.pre.async)) || c.conf.Regions != RegionsDefault
+}
+
+// printModule выводит модуль по частям: переменные, методы, методы с &ИзменениеИКонтроль без изменений, код основной программы,
+// каждую часть в своих областях и условиях препроцессора
+func (c *Obfuscator) printModule(conf ast.PrintConf) string {
+	var b strings.Builder
+	var current []preBlock
+
+	emit := func(path []preBlock, text string) {
+		k := 0
+		for k < len(current) && k < len(path) && current[k] == path[k] {
+			k++
+		}
+		for i := len(current) - 1; i >= k; i-- {
+			b.WriteString(ast.IF(current[i].region, "#КонецОбласти\n", "#КонецЕсли\n"))
+		}
+		for _, block := range path[k:] {
+			if block.region {
+				b.WriteString("#Область " + block.text + "\n")
+			} else {
+				b.WriteString("#Если " + block.text + " Тогда\n")
+			}
+		}
+
+		current = path
+		b.WriteString(strings.TrimRight(text, "\n") + "\n")
+	}
+
+	module := &c.a.ModuleStatement
+	for _, v := range c.moduleVariables(module) {
+		text := "Перем " + v.variable.Var.Name + ast.IF(v.variable.Export, " Экспорт", "") + ";"
+		if v.variable.Directive != "" {
+			text = v.variable.Directive + "\n" + text
+		}
+		emit(c.regions(v.path), text)
+	}
+
+	controlledDone := false
+	controlled := func() {
+		if c.pre == nil || controlledDone {
+			return
+		}
+		for _, item := range c.pre.controlled {
+			emit(c.regions(item.path), item.text)
+		}
+		controlledDone = true
+	}
+
+	decoy, left := "", 0
+	var mainPath []preBlock
+	for _, stm := range module.Body {
+		f, ok := stm.(*ast.FunctionOrProcedure)
+		if !ok {
+			if m, ok := c.pre.mainMarker(stm); ok {
+				mainPath = m.path
+				continue
+			}
+
+			controlled()
+			emit(mainPath, c.a.PrintStatementWithConf(stm, conf))
+			continue
+		}
+
+		path := c.regions(c.placement[f])
+		if c.conf.Regions == RegionsDecoy {
+			if left == 0 {
+				decoy, left = decoyRegions[c.random(0, len(decoyRegions))], int(c.random(1, 5))
+			}
+			path = append([]preBlock{{region: true, text: decoy}}, path...)
+			left--
+		}
+
+		var text string
+		if c.isAsync(f) {
+			text = c.printAsync(f, conf)
+		} else {
+			text = c.a.PrintStatementWithConf(f, conf)
+		}
+		emit(path, withAnnotations(text, f.Name, c.extAnnotations[f]))
+	}
+
+	controlled()
+	emit(nil, "")
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// regions путь с учетом режима вывода областей
+func (c *Obfuscator) regions(path []preBlock) []preBlock {
+	if c.conf.Regions == RegionsRemove || c.conf.Regions == RegionsDecoy {
+		return conditions(path)
+	}
+
+	return path
+}
+
+type moduleVariable struct {
+	variable ast.GlobalVariables
+	path     []preBlock
+}
+
+// moduleVariables переменные модуля в порядке объявления
+func (c *Obfuscator) moduleVariables(module *ast.ModuleStatement) []moduleVariable {
+	byName := map[string]ast.GlobalVariables{}
+	for _, v := range module.GlobalVariables {
+		byName[strings.ToLower(v.Var.Name)] = v
+	}
+
+	var result []moduleVariable
+	if c.pre != nil {
+		for _, item := range c.pre.variables {
+			name := item.name
+			if newName, ok := c.varRenames[name]; ok {
+				name = strings.ToLower(newName)
+			}
+
+			if v, ok := byName[name]; ok {
+				result = append(result, moduleVariable{variable: v, path: item.path})
+				delete(byName, name)
+			}
+		}
+	}
+
+	// не найденные при разборе текста выводятся первыми
+	var rest []string
+	for name := range byName {
+		rest = append(rest, name)
+	}
+	sort.Strings(rest)
+
+	first := make([]moduleVariable, 0, len(rest)+len(result))
+	for _, name := range rest {
+		first = append(first, moduleVariable{variable: byName[name]})
+	}
+
+	return append(first, result...)
+}
@@ -0,0 +1,170 @@
+package obfuscator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPreprocessorModule = `Перем Кэш;
+
+#Если Сервер Или ТолстыйКлиентОбычноеПриложение Или ВнешнееСоединение Тогда
+
+#Область ПрограммныйИнтерфейс
+
+Функция Данные() Экспорт
+	Возврат "сервер";
+КонецФункции
+
+#КонецОбласти
+
+#Иначе
+
+Функция Данные() Экспорт
+	Возврат "клиент";
+КонецФункции
+
+#КонецЕсли
+
+Процедура Очистить()
+	Кэш = Неопределено;
+КонецПроцедуры`
+
+// шаблон модуля объекта БСП
+const testBSPModule = `#Если Сервер Или ТолстыйКлиентОбычноеПриложение Или ВнешнееСоединение Тогда
+
+#Область ПрограммныйИнтерфейс
+
+Процедура ЗаполнитьРеквизиты() Экспорт
+	#Если Сервер Тогда
+	Источник = "сервер";
+	#Иначе
+	Источник = "толстый клиент";
+	#КонецЕсли
+	Сообщить(Источник);
+КонецПроцедуры
+
+#КонецОбласти
+
+#Область ОбработчикиСобытий
+
+Процедура ПередЗаписью(Отказ)
+	Если Отказ Тогда
+		Возврат;
+	КонецЕсли;
+КонецПроцедуры
+
+#КонецОбласти
+
+#Иначе
+ВызватьИсключение НСтр("ru = 'Недопустимый вызов объекта на клиенте.'");
+#КонецЕсли`
+
+func TestParsePreprocessor(t *testing.T) {
+	pre, err := parsePreprocessor(testPreprocessorModule)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	server := preBlock{text: "Сервер Или ТолстыйКлиентОбычноеПриложение Или ВнешнееСоединение"}
+	assert.True(t, pre.conditional)
+	assert.Equal(t, []preItem{
+		{name: "данные", path: []preBlock{server, {region: true, text: "ПрограммныйИнтерфейс"}}},
+		{name: "данные", path: []preBlock{{text: "Не (" + server.text + ")"}}},
+		{name: "очистить"},
+	}, pre.methods)
+	assert.Equal(t, []preItem{{name: "кэш"}}, pre.variables)
+
+	_, err = parsePreprocessor("Процедура А()\n#Если Клиент Тогда\nКонецПроцедуры")
+	assert.Error(t, err)
+
+	_, err = parsePreprocessor("Процедура А()\n#КонецЕсли\nКонецПроцедуры")
+	assert.Error(t, err)
+
+	assert.Nil(t, conditionContexts(server.text))
+	assert.Equal(t, []CompilationContext{ContextServer}, conditionContexts("Сервер Или ВнешнееСоединение"))
+	assert.Equal(t, []CompilationContext{ContextClient}, conditionContexts("ТонкийКлиент Или ВебКлиент"))
+	assert.Nil(t, conditionContexts("Не Сервер"))
+}
+
+func TestParsePreprocessorBSP(t *testing.T) {
+	pre, err := parsePreprocessor(testBSPModule)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	server := "Сервер Или ТолстыйКлиентОбычноеПриложение Или ВнешнееСоединение"
+	var lines []string
+	var main []preMarker
+	for name, m := range pre.markers {
+		assert.Contains(t, pre.source, "~"+name+":")
+		if m.main {
+			main = append(main, m)
+		} else {
+			lines = append(lines, m.line)
+		}
+	}
+
+	assert.ElementsMatch(t, []string{"#Если Сервер Тогда", "#Иначе", "#КонецЕсли"}, lines)
+	assert.Equal(t, []preMarker{{main: true, path: []preBlock{{text: "Не (" + server + ")"}}}}, main)
+	assert.NotContains(t, pre.source, "#Если Сервер Тогда")
+	assert.Contains(t, pre.source, `ВызватьИсключение НСтр(`)
+
+	restored := pre.restoreMarkers(pre.source)
+	assert.Contains(t, restored, "\n#Если Сервер Тогда\n")
+	assert.Contains(t, restored, "\n#Иначе\n")
+	assert.Equal(t, 2, strings.Count(restored, "#КонецЕсли"))
+}
+
+func TestObfuscatePreprocessorBSP(t *testing.T) {
+	for _, conf := range []Config{
+		{HideString: true, RenameVariables: true},
+		{RepExpByTernary: true, ChangeConditions: true, AppendGarbage: true, Regions: RegionsKeep},
+	} {
+		obf := NewObfuscatory(context.Background(), conf)
+		obCode, err := obf.ObfuscateModule(testBSPModule, ModuleInfo{Kind: ModuleObject})
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		assert.Contains(t, obCode, "#Если Сервер Тогда")
+		assert.Contains(t, obCode, "#Иначе")
+		assert.Equal(t, strings.Count(obCode, "#Если"), strings.Count(obCode, "#КонецЕсли"))
+
+		client := strings.Index(obCode, "#Если Не (Сервер Или ТолстыйКлиентОбычноеПриложение Или ВнешнееСоединение) Тогда")
+		raise := strings.Index(obCode, "ВызватьИсключение")
+		if assert.GreaterOrEqual(t, client, 0) {
+			assert.Less(t, client, raise)
+			assert.Contains(t, obCode[client:], "#КонецЕсли")
+		}
+	}
+}
+
+func TestObfuscatePreprocessor(t *testing.T) {
+	obf := NewObfuscatory(context.Background(), Config{HideString: true, CallStackHell: true})
+	obCode, err := obf.ObfuscateModule(testPreprocessorModule, ModuleInfo{Kind: ModuleCommon})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, strings.Count(obCode, "#Если"))
+		assert.Equal(t, 2, strings.Count(obCode, "#КонецЕсли"))
+		assert.Contains(t, obCode, "#Если Не (Сервер Или ТолстыйКлиентОбычноеПриложение Или ВнешнееСоединение) Тогда")
+		assert.Contains(t, obCode, "#Область ПрограммныйИнтерфейс")
+		assert.NotContains(t, obCode, `"сервер"`)
+	}
+
+	obf = NewObfuscatory(context.Background(), Config{Regions: RegionsRemove})
+	obCode, err = obf.ObfuscateModule(testPreprocessorModule, ModuleInfo{Kind: ModuleCommon})
+	if assert.NoError(t, err) {
+		assert.NotContains(t, obCode, "#Область")
+		assert.Contains(t, obCode, "#Если Сервер")
+	}
+
+	obf = NewObfuscatory(context.Background(), Config{Regions: RegionsDecoy})
+	obCode, err = obf.ObfuscateModule(testPreprocessorModule, ModuleInfo{Kind: ModuleCommon})
+	if assert.NoError(t, err) {
+		assert.NotContains(t, obCode, "#Область ПрограммныйИнтерфейс\n")
+		assert.Equal(t, strings.Count(obCode, "#Область"), strings.Count(obCode, "#КонецОбласти"))
+		assert.Greater(t, strings.Count(obCode, "#Область"), 0)
+	}
+}
@@ -0,0 +1,80 @@
+package obfuscator
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Preset готовый набор настроек
+type Preset string
+
+const (
+	// PresetPerformanceSafe только преобразования без затрат при выполнении: переименование методов и переменных,
+	// циклы заменяются на Перейти, "горячий" код не меняется (Config.Performance).
+	// Строки, выражения и условия остаются открытыми
+	PresetPerformanceSafe Preset = "performance-safe"
+
+	// PresetLight прячутся строки и циклы, на каждое обращение к строке добавляется вызов функции-декодера.
+	// Подходит для большинства модулей
+	PresetLight Preset = "light"
+
+	// PresetBalanced к Light добавляются тернарные операторы, изменение условий и мусор.
+	// Код вырастает в несколько раз, замедление заметно только на "горячих" участках
+	PresetBalanced Preset = "balanced"
+
+	// PresetParanoid все преобразования, включая Выполнить()/Вычислить(), ветвление через исключения и CallStackHell.
+	// Код вырастает на порядок, выражения выполняются через Вычислить(), что значительно медленнее,
+	// не подходит для кода, который выполняется в циклах и в безопасном режиме
+	PresetParanoid Preset = "paranoid"
+)
+
+// Presets все доступные пресеты, от самого быстрого к самому защищенному
+func Presets() []Preset {
+	return []Preset{PresetPerformanceSafe, PresetLight, PresetBalanced, PresetParanoid}
+}
+
+// Config настройки пресета
+func (p Preset) Config() (Config, error) {
+	switch Preset(strings.ToLower(string(p))) {
+	case PresetPerformanceSafe:
+		return Config{
+			RenameMethods:   true,
+			RenameVariables: true,
+			RepLoopByGoto:   true,
+			Performance:     true,
+		}, nil
+	case PresetLight:
+		return Config{
+			RepLoopByGoto: true,
+			HideString:    true,
+		}, nil
+	case PresetBalanced:
+		return Config{
+			RepExpByTernary:  true,
+			RepLoopByGoto:    true,
+			HideString:       true,
+			ChangeConditions: true,
+			AppendGarbage:    true,
+		}, nil
+	case PresetParanoid:
+		return Config{
+			RepExpByTernary:  true,
+			RepLoopByGoto:    true,
+			RepExpByEval:     true,
+			HideString:       true,
+			ChangeConditions: true,
+			AppendGarbage:    true,
+			ExceptionFlow:    true,
+			CallStackHell:    true,
+			Intensity: Intensity{
+				Ternary:    Level{MinDepth: 3, MaxDepth: 6},
+				Conditions: Level{MinDepth: 4, MaxDepth: 6, MaxNesting: ptr(7)},
+				Garbage:    Level{Probability: ptr(0.7), MaxNesting: ptr(4)},
+				CallStack:  Level{MinDepth: 5, MaxDepth: 10},
+			},
+		}, nil
+	default:
+		return Config{}, errors.Errorf("unknown preset %q", p)
+	}
+}
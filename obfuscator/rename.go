@@ -0,0 +1,119 @@
+package obfuscator
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+)
+
+// PassRename переименование методов модуля
+const PassRename = "rename"
+
+// имена методов, которые передаются строкой (ОписаниеОповещения, ПодключитьОбработчикОжидания и т.п.)
+var identStringRe = regexp.MustCompile(`"([\p{L}_][\p{L}\p{N}_]*)"`)
+
+// renameTransform переименовывает неэкспортные методы модуля и их вызовы.
+// Не переименовываются обработчики событий платформы, методы с аннотацией keep-name,
+// методы, имя которых встречается в строке, и методы, которые вызываются через точку.
+// Экспортные методы переименовываются, только если они не видны снаружи (модуль команды)
+// или все ссылки на них известны (обработчики из описаний метаданных в ObfuscateDir)
+type renameTransform struct{}
+
+func (renameTransform) Name() string {
+	return PassRename
+}
+
+func (renameTransform) Apply(env *TransformEnv, module *ast.ModuleStatement) error {
+	c := env.obf
+
+	keep := c.referencedNames(module)
+	for _, name := range c.conf.PreservedNames {
+		keep[strings.ToLower(name)] = struct{}{}
+	}
+
+	exports := toSet(nil)
+	for _, name := range c.conf.renameExports {
+		exports[strings.ToLower(name)] = struct{}{}
+	}
+
+	newNames := map[string]string{}
+	for _, item := range module.Body {
+		f, ok := item.(*ast.FunctionOrProcedure)
+		if !ok || c.isGenerated(f) || !c.allowed(f, PassRename) || c.methodSettings(f).keepName {
+			continue
+		}
+		if _, ok := exports[strings.ToLower(f.Name)]; f.Export && !ok && c.module.exportsVisible() {
+			continue
+		}
+
+		key := strings.ToLower(f.Name)
+		if _, ok := keep[key]; ok || IsPreservedName(c.module.Kind, f.Name) {
+			continue
+		}
+
+		newNames[key] = env.Names.Name(c.intensity.Names.Function)
+	}
+
+	if len(newNames) == 0 {
+		return nil
+	}
+
+	mapStatements(module, func(stm ast.Statement) ast.Statement {
+		switch v := stm.(type) {
+		case *ast.FunctionOrProcedure:
+			if name, ok := newNames[strings.ToLower(v.Name)]; ok {
+				c.renamed(v.Name, name)
+				v.Name = name
+			}
+		case ast.MethodStatement:
+			if name, ok := newNames[strings.ToLower(v.Name)]; ok {
+				v.Name = name
+				return v
+			}
+		}
+
+		return stm
+	})
+
+	return nil
+}
+
+// referencedNames имена, на которые есть ссылки, по которым нельзя безопасно переименовать метод,
+// и имена из методов с &ИзменениеИКонтроль
+func (c *Obfuscator) referencedNames(module *ast.ModuleStatement) map[string]struct{} {
+	result := c.controlledIdents()
+	for _, m := range identStringRe.FindAllStringSubmatch(c.source, -1) {
+		result[strings.ToLower(m[1])] = struct{}{}
+	}
+
+	mapStatements(module, func(stm ast.Statement) ast.Statement {
+		if chain, ok := stm.(ast.CallChainStatement); ok {
+			for _, part := range []ast.Statement{chain.Unit, chain.Call} {
+				if m, ok := part.(ast.MethodStatement); ok {
+					result[strings.ToLower(m.Name)] = struct{}{}
+				}
+			}
+		}
+
+		return stm
+	})
+
+	return result
+}
+
+// renamed запоминает новое имя метода
+func (c *Obfuscator) renamed(oldName, newName string) {
+	c.methods[strings.ToLower(newName)] = c.methodSettings(&ast.FunctionOrProcedure{Name: oldName})
+	c.renames[strings.ToLower(oldName)] = newName
+}
+
+// RenamedMethods методы, переименованные при последней обфускации: старое имя в нижнем регистре -> новое имя
+func (c *Obfuscator) RenamedMethods() map[string]string {
+	result := make(map[string]string, len(c.renames))
+	for k, v := range c.renames {
+		result[k] = v
+	}
+
+	return result
+}
@@ -0,0 +1,90 @@
+package obfuscator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreservedNames(t *testing.T) {
+	assert.True(t, IsPreservedName(ModuleForm, "ПриСозданииНаСервере"))
+	assert.True(t, IsPreservedName(ModuleObject, "обработкапроведения"))
+	assert.True(t, IsPreservedName(ModuleUnknown, "ПриНачалеРаботыСистемы"))
+	assert.False(t, IsPreservedName(ModuleCommon, "ПриСозданииНаСервере"))
+	assert.False(t, IsPreservedName(ModuleUnknown, "ЗаполнитьТаблицу"))
+
+	RegisterPreservedNames(ModuleCommon, "ОбработчикИзБиблиотеки")
+	assert.True(t, IsPreservedName(ModuleCommon, "ОбработчикИзБиблиотеки"))
+
+	version, platform := KnowledgeBaseVersion()
+	assert.NotEmpty(t, version)
+	assert.NotEmpty(t, platform)
+}
+
+func TestMapStatements(t *testing.T) {
+	module := &ast.ModuleStatement{Body: ast.Statements{
+		&ast.FunctionOrProcedure{Name: "Метод", Body: ast.Statements{
+			ast.MethodStatement{Name: "Сообщить", Param: ast.ExprStatements{Statements: ast.Statements{
+				ast.MethodStatement{Name: "Метод"},
+			}}},
+		}},
+	}}
+
+	var count int
+	mapStatements(module, func(stm ast.Statement) ast.Statement {
+		if m, ok := stm.(ast.MethodStatement); ok && m.Name == "Метод" {
+			count++
+			m.Name = "Новый"
+			return m
+		}
+		return stm
+	})
+
+	call := module.Body[0].(*ast.FunctionOrProcedure).Body[0].(ast.MethodStatement)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, "Новый", call.Param.Statements[0].(ast.MethodStatement).Name)
+}
+
+func TestRenameMethods(t *testing.T) {
+	code := `&НаСервере
+Процедура ПриСозданииНаСервере(Отказ, СтандартнаяОбработка)
+	ЗаполнитьТаблицу();
+КонецПроцедуры
+
+&НаСервере
+Процедура ЗаполнитьТаблицу()
+	Сообщить(ПосчитатьИтог());
+КонецПроцедуры
+
+&НаСервере
+Функция ПосчитатьИтог()
+	Возврат 1;
+КонецФункции
+
+&НаКлиенте
+Процедура НачатьВыбор()
+	Оповещение = Новый ОписаниеОповещения("ВыборЗавершение", ЭтотОбъект);
+КонецПроцедуры
+
+&НаКлиенте
+Процедура ВыборЗавершение(Результат, Параметры) Экспорт
+КонецПроцедуры
+
+// obfuscate:keep-name
+&НаСервере
+Процедура НеПереименовывать()
+КонецПроцедуры`
+
+	obf := NewObfuscatory(context.Background(), Config{RenameMethods: true})
+	obCode, err := obf.Obfuscate(code)
+	if assert.NoError(t, err) {
+		assert.Contains(t, obCode, "ПриСозданииНаСервере")
+		assert.Contains(t, obCode, "ВыборЗавершение")
+		assert.Contains(t, obCode, "НеПереименовывать")
+		assert.NotContains(t, obCode, "ЗаполнитьТаблицу")
+		assert.NotContains(t, obCode, "ПосчитатьИтог")
+		assert.NotContains(t, obCode, "НачатьВыбор")
+	}
+}
@@ -0,0 +1,251 @@
+package obfuscator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+)
+
+// Report отчет об обфускации модуля
+type Report struct {
+	Path string `json:"path,omitempty"`
+
+	// SizeBefore, SizeAfter размер модуля в байтах, LinesBefore, LinesAfter - в строках
+	SizeBefore  int `json:"sizeBefore"`
+	SizeAfter   int `json:"sizeAfter"`
+	LinesBefore int `json:"linesBefore"`
+	LinesAfter  int `json:"linesAfter"`
+
+	// Transforms сколько изменений сделал каждый проход: спрятанные строки, циклы, замененные на Перейти,
+	// переименованные методы и т.д. Изменения в служебном коде (декодеры строк) не учитываются
+	Transforms map[string]int `json:"transforms"`
+
+	// GeneratedFunctions добавленные служебные функции: декодеры строк и фейковые функции
+	GeneratedFunctions int `json:"generatedFunctions"`
+
+	// Procedures методы модуля, Skipped - методы, которые не обфусцировались
+	Procedures int                `json:"procedures"`
+	Skipped    []SkippedProcedure `json:"skipped,omitempty"`
+
+	// Overhead оценка накладных расходов при выполнении по методам, от самых затратных
+	Overhead []ProcedureOverhead `json:"overhead,omitempty"`
+
+	Warnings int `json:"warnings"`
+	Errors   int `json:"errors"`
+}
+
+// SkippedProcedure метод, который выведен без изменений
+type SkippedProcedure struct {
+	Path      string `json:"path,omitempty"`
+	Procedure string `json:"procedure"`
+	Reason    string `json:"reason"`
+}
+
+// Growth во сколько раз вырос модуль
+func (r Report) Growth() float64 {
+	if r.SizeBefore == 0 {
+		return 0
+	}
+
+	return float64(r.SizeAfter) / float64(r.SizeBefore)
+}
+
+// count учитывает изменение в отчете за проход pass, который его сделал, даже если изменение сделано
+// во время другого прохода (мусор в блоках условий). Служебный код выключенного прохода не учитывается
+func (c *Obfuscator) count(pass string) {
+	if _, ok := c.activePasses[pass]; !ok {
+		return
+	}
+	if c.stats == nil {
+		c.stats = map[string]int{}
+	}
+
+	c.stats[pass]++
+	c.countProcedure(pass)
+}
+
+// buildReport отчет о последнем запуске по исходному коду, результату и диагностикам
+func (c *Obfuscator) buildReport(code, obCode string, diagnostics []Diagnostic) Report {
+	r := Report{
+		Path:        c.module.Path,
+		SizeBefore:  len(code),
+		SizeAfter:   len(obCode),
+		LinesBefore: lines(code),
+		LinesAfter:  lines(obCode),
+		Transforms:  map[string]int{},
+	}
+	if obCode == "" {
+		r.SizeAfter, r.LinesAfter = r.SizeBefore, r.LinesBefore
+	}
+
+	for pass, n := range c.stats {
+		r.Transforms[pass] = n
+	}
+	if len(c.renames) > 0 {
+		r.Transforms[PassRename] = len(c.renames)
+	}
+	if len(c.varRenames) > 0 {
+		r.Transforms[PassVariables] = len(c.varRenames)
+	}
+
+	if c.a != nil {
+		for _, stm := range c.a.ModuleStatement.Body {
+			f, ok := stm.(*ast.FunctionOrProcedure)
+			switch {
+			case !ok:
+			case c.isGenerated(f):
+				r.GeneratedFunctions++
+			default:
+				r.Procedures++
+				if c.methodSettings(f).off {
+					_, name := procedureDeclaration(c.source, c.sourceName(f.Name))
+					r.Skipped = append(r.Skipped, SkippedProcedure{Path: r.Path, Procedure: name, Reason: "obfuscate:off"})
+				}
+			}
+		}
+	}
+	if c.pre != nil {
+		for _, item := range c.pre.controlled {
+			r.Procedures++
+			r.Skipped = append(r.Skipped, SkippedProcedure{Path: r.Path, Procedure: item.name, Reason: ast.IF(item.await, "Ждать", "&ИзменениеИКонтроль")})
+		}
+	}
+
+	r.Overhead = c.overhead(r.Path)
+
+	for _, d := range diagnostics {
+		switch d.Severity {
+		case SeverityError:
+			r.Errors++
+		case SeverityWarning:
+			r.Warnings++
+		}
+	}
+
+	return r
+}
+
+func lines(code string) int {
+	if code == "" {
+		return 0
+	}
+
+	return strings.Count(strings.TrimSuffix(code, "\n"), "\n") + 1
+}
+
+// maxOverheadRows сколько самых затратных методов выводится в Markdown
+const maxOverheadRows = 20
+
+// Summary сводный отчет по модулям конфигурации
+type Summary struct {
+	ModuleCount int `json:"moduleCount"`
+
+	SizeBefore  int `json:"sizeBefore"`
+	SizeAfter   int `json:"sizeAfter"`
+	LinesBefore int `json:"linesBefore"`
+	LinesAfter  int `json:"linesAfter"`
+
+	Transforms         map[string]int     `json:"transforms"`
+	GeneratedFunctions int                `json:"generatedFunctions"`
+	Procedures         int                `json:"procedures"`
+	Skipped            []SkippedProcedure `json:"skipped,omitempty"`
+
+	// Overhead методы всех модулей, от самых затратных
+	Overhead []ProcedureOverhead `json:"overhead,omitempty"`
+
+	Warnings int `json:"warnings"`
+	Errors   int `json:"errors"`
+
+	Modules []Report `json:"modules"`
+}
+
+// Summarize суммирует отчеты модулей, модули упорядочиваются по пути
+func Summarize(reports []Report) Summary {
+	s := Summary{
+		ModuleCount: len(reports),
+		Transforms:  map[string]int{},
+		Modules:     append([]Report{}, reports...),
+	}
+	sort.SliceStable(s.Modules, func(i, j int) bool { return s.Modules[i].Path < s.Modules[j].Path })
+
+	for _, r := range s.Modules {
+		s.SizeBefore += r.SizeBefore
+		s.SizeAfter += r.SizeAfter
+		s.LinesBefore += r.LinesBefore
+		s.LinesAfter += r.LinesAfter
+		s.GeneratedFunctions += r.GeneratedFunctions
+		s.Procedures += r.Procedures
+		s.Skipped = append(s.Skipped, r.Skipped...)
+		s.Overhead = append(s.Overhead, r.Overhead...)
+		s.Warnings += r.Warnings
+		s.Errors += r.Errors
+		for pass, n := range r.Transforms {
+			s.Transforms[pass] += n
+		}
+	}
+
+	sortOverhead(s.Overhead)
+	return s
+}
+
+// Markdown сводный отчет для просмотра человеком
+func (s Summary) Markdown() string {
+	var b strings.Builder
+	total := Report{SizeBefore: s.SizeBefore, SizeAfter: s.SizeAfter}
+
+	b.WriteString("# Отчет об обфускации\n\n")
+	fmt.Fprintf(&b, "| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Модулей | %d |\n", s.ModuleCount)
+	fmt.Fprintf(&b, "| Методов | %d, пропущено %d |\n", s.Procedures, len(s.Skipped))
+	fmt.Fprintf(&b, "| Размер, байт | %d → %d (×%.1f) |\n", s.SizeBefore, s.SizeAfter, total.Growth())
+	fmt.Fprintf(&b, "| Строк | %d → %d |\n", s.LinesBefore, s.LinesAfter)
+	fmt.Fprintf(&b, "| Служебных функций | %d |\n", s.GeneratedFunctions)
+	fmt.Fprintf(&b, "| Предупреждений | %d |\n", s.Warnings)
+	fmt.Fprintf(&b, "| Ошибок | %d |\n", s.Errors)
+
+	if len(s.Transforms) > 0 {
+		b.WriteString("\n## Преобразования\n\n| Проход | Изменений |\n|---|---|\n")
+		passes := make([]string, 0, len(s.Transforms))
+		for pass := range s.Transforms {
+			passes = append(passes, pass)
+		}
+		sort.Strings(passes)
+		for _, pass := range passes {
+			fmt.Fprintf(&b, "| %s | %d |\n", pass, s.Transforms[pass])
+		}
+	}
+
+	if len(s.Skipped) > 0 {
+		b.WriteString("\n## Пропущенные методы\n\n| Модуль | Метод | Причина |\n|---|---|---|\n")
+		for _, p := range s.Skipped {
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", cell(p.Path), cell(p.Procedure), cell(p.Reason))
+		}
+	}
+
+	if len(s.Overhead) > 0 {
+		b.WriteString("\n## Накладные расходы\n\n| Модуль | Метод | Стоимость | В циклах | Нагруженный |\n|---|---|---|---|---|\n")
+		for i, o := range s.Overhead {
+			if i == maxOverheadRows {
+				fmt.Fprintf(&b, "| ... | еще %d | | | |\n", len(s.Overhead)-i)
+				break
+			}
+			fmt.Fprintf(&b, "| %s | %s | %d | %d | %s |\n", cell(o.Path), cell(o.Procedure), o.Cost, o.InLoops, ast.IF(o.Hot, "да", ""))
+		}
+	}
+
+	if len(s.Modules) > 0 {
+		b.WriteString("\n## Модули\n\n| Модуль | Размер, байт | Рост | Предупреждений | Ошибок |\n|---|---|---|---|---|\n")
+		for _, r := range s.Modules {
+			fmt.Fprintf(&b, "| %s | %d → %d | ×%.1f | %d | %d |\n", cell(r.Path), r.SizeBefore, r.SizeAfter, r.Growth(), r.Warnings, r.Errors)
+		}
+	}
+
+	return b.String()
+}
+
+// cell текст ячейки таблицы Markdown: | внутри значения разделял бы столбцы
+func cell(text string) string {
+	return strings.ReplaceAll(text, "|", `\|`)
+}
@@ -0,0 +1,87 @@
+package obfuscator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReport(t *testing.T) {
+	code := `Процедура Тест()
+	Сообщить("Привет");
+	Сообщить("Мир");
+КонецПроцедуры
+
+// obfuscate:off
+Процедура Открытая()
+	Сообщить("Как есть");
+КонецПроцедуры`
+
+	obf := NewObfuscatory(context.Background(), Config{HideString: true})
+	result, err := obf.ObfuscateResult(code, ModuleInfo{Path: "CommonModules/Тест/Ext/Module.bsl"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	r := result.Report
+	assert.Equal(t, "CommonModules/Тест/Ext/Module.bsl", r.Path)
+	assert.Equal(t, len(code), r.SizeBefore)
+	assert.Equal(t, len(result.Code), r.SizeAfter)
+	assert.Equal(t, 9, r.LinesBefore)
+	assert.Equal(t, map[string]int{PassStrings: 2}, r.Transforms)
+	assert.Equal(t, 1, r.GeneratedFunctions)
+	assert.Equal(t, 2, r.Procedures)
+	assert.Equal(t, []SkippedProcedure{{Path: r.Path, Procedure: "Открытая", Reason: "obfuscate:off"}}, r.Skipped)
+
+	// отчет относится к последнему запуску
+	result, err = obf.ObfuscateResult(`Процедура Тест()
+КонецПроцедуры`, ModuleInfo{})
+	assert.NoError(t, err)
+	assert.Empty(t, result.Report.Transforms)
+	assert.Empty(t, result.Report.Skipped)
+}
+
+func TestSummarize(t *testing.T) {
+	s := Summarize([]Report{
+		{Path: "b.bsl", SizeBefore: 100, SizeAfter: 300, Transforms: map[string]int{PassStrings: 2, PassGoto: 1}, Warnings: 1},
+		{Path: "a.bsl", SizeBefore: 50, SizeAfter: 100, Transforms: map[string]int{PassStrings: 3},
+			Skipped: []SkippedProcedure{{Path: "a.bsl", Procedure: "Тест", Reason: "obfuscate:off"}}},
+	})
+
+	assert.Equal(t, 2, s.ModuleCount)
+	assert.Equal(t, 150, s.SizeBefore)
+	assert.Equal(t, 400, s.SizeAfter)
+	assert.Equal(t, map[string]int{PassStrings: 5, PassGoto: 1}, s.Transforms)
+	assert.Equal(t, 1, s.Warnings)
+	assert.Len(t, s.Skipped, 1)
+	assert.Equal(t, "a.bsl", s.Modules[0].Path)
+
+	md := s.Markdown()
+	assert.Contains(t, md, "| Размер, байт | 150 → 400 (×2.7) |")
+	assert.Contains(t, md, "| goto | 1 |\n| strings | 5 |")
+	assert.Contains(t, md, "| a.bsl | Тест | obfuscate:off |")
+	assert.Contains(t, md, "| b.bsl | 100 → 300 | ×3.0 | 1 | 0 |")
+}
+
+func TestCountOwnerPass(t *testing.T) {
+	c := &Obfuscator{pass: PassConditions, activePasses: toSet([]string{PassConditions, PassGarbage})}
+
+	// мусор в блоках условий добавляется во время прохода conditions, но относится к garbage
+	c.count(PassGarbage)
+	c.count(PassConditions)
+	// декодер строк заменяет свой цикл на Перейти и без прохода goto
+	c.count(PassGoto)
+
+	assert.Equal(t, map[string]int{PassConditions: 1, PassGarbage: 1}, c.stats)
+}
+
+func TestMarkdownEscape(t *testing.T) {
+	md := Summarize([]Report{{
+		Path:    "a|b.bsl",
+		Skipped: []SkippedProcedure{{Path: "a|b.bsl", Procedure: "Тест", Reason: "obfuscate:off"}},
+	}}).Markdown()
+
+	assert.Contains(t, md, `| a\|b.bsl | Тест | obfuscate:off |`)
+	assert.NotContains(t, md, "| a|b.bsl |")
+}
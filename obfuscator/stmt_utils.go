@@ -1,6 +1,10 @@
 package obfuscator
 
-import "github.com/LazarenkoA/1c-language-parser/ast"
+import (
+	"reflect"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+)
 
 func (c *Obfuscator) isIf(stm *ast.Statement) bool {
 	_, ok := (*stm).(*ast.IfStatement)
@@ -27,3 +31,42 @@ func (c *Obfuscator) isLoop(stm ast.Statement) bool {
 	_, ok2 := stm.(ast.LoopStatement)
 	return ok1 || ok2
 }
+
+// mapStatements обходит все узлы дерева, включая вложенные выражения, и заменяет каждый узел результатом fn.
+// Вложенные узлы обрабатываются раньше родительских. node должен быть указателем
+func mapStatements(node interface{}, fn func(stm ast.Statement) ast.Statement) {
+	mapValue(reflect.ValueOf(node), fn)
+}
+
+func mapValue(v reflect.Value, fn func(stm ast.Statement) ast.Statement) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			mapValue(v.Elem(), fn)
+		}
+	case reflect.Interface:
+		if v.IsNil() || !v.CanSet() {
+			return
+		}
+
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		mapValue(elem, fn)
+
+		if result := fn(elem.Interface()); result == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(result))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Field(i); f.CanSet() {
+				mapValue(f, fn)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			mapValue(v.Index(i), fn)
+		}
+	}
+}
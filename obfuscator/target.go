@@ -0,0 +1,209 @@
+package obfuscator
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+	"github.com/pkg/errors"
+)
+
+// ClientType тип клиентского приложения
+type ClientType string
+
+const (
+	ClientThin   ClientType = "thin"
+	ClientThick  ClientType = "thick"
+	ClientWeb    ClientType = "web"
+	ClientMobile ClientType = "mobile"
+)
+
+// bitwiseVersion версия платформы, в которой появились ПобитовоеИ, ПобитовоеИНе, ПобитовыйСдвигВлево и т.д.
+const bitwiseVersion = "8.3.11"
+
+// binaryStringsVersion версия платформы, в которой появилась ПолучитьСтрокуИзДвоичныхДанных
+const binaryStringsVersion = "8.3.9"
+
+// codeDigits длина кода символа в строках декодера без двоичных данных, вмещает любой код Unicode со сдвигом ключа
+const codeDigits = 7
+
+// Target платформа, на которой будет выполняться код. Проходы, которые используют недоступные возможности,
+// отключаются или заменяются. Пустой Target - последняя версия платформы, тонкий и толстый клиент
+type Target struct {
+	// PlatformVersion минимальная версия платформы, например 8.3.10. Пусто - последняя версия
+	PlatformVersion string `yaml:"platformVersion,omitempty" json:"platformVersion,omitempty"`
+
+	// CompatibilityMode режим совместимости конфигурации, например 8.3.10. Возможности новее режима совместимости недоступны
+	CompatibilityMode string `yaml:"compatibilityMode,omitempty" json:"compatibilityMode,omitempty"`
+
+	// SafeMode код выполняется в безопасном режиме (внешние обработки в БСП, облачные сервисы), Выполнить()/Вычислить() недоступны
+	SafeMode bool `yaml:"safeMode,omitempty" json:"safeMode,omitempty"`
+
+	// Clients клиентские приложения. В мобильном приложении Выполнить()/Вычислить() недоступны,
+	// в веб-клиенте они не используются в клиентском коде
+	Clients []ClientType `yaml:"clients,omitempty" json:"clients,omitempty"`
+}
+
+func (t Target) validate() error {
+	for _, version := range []string{t.PlatformVersion, t.CompatibilityMode} {
+		if version == "" {
+			continue
+		}
+		if _, err := parseVersion(version); err != nil {
+			return err
+		}
+	}
+
+	for _, client := range t.Clients {
+		switch client {
+		case ClientThin, ClientThick, ClientWeb, ClientMobile:
+		default:
+			return errors.Errorf("unknown client type %q", client)
+		}
+	}
+
+	return nil
+}
+
+func (t Target) hasClient(client ClientType) bool {
+	for _, c := range t.Clients {
+		if c == client {
+			return true
+		}
+	}
+
+	return false
+}
+
+// declared задан ли Target явно
+func (t Target) declared() bool {
+	return t.PlatformVersion != "" || t.CompatibilityMode != "" || t.SafeMode || len(t.Clients) > 0
+}
+
+// atLeast поддерживает ли целевая платформа с учетом режима совместимости возможности версии version
+func (t Target) atLeast(version string) bool {
+	required, _ := parseVersion(version)
+
+	for _, v := range []string{t.PlatformVersion, t.CompatibilityMode} {
+		if v == "" {
+			continue
+		}
+
+		target, err := parseVersion(v)
+		if err != nil || versionLess(target, required) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func versionLess(a, b [4]int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+
+	return false
+}
+
+// bitwise доступны ли побитовые функции
+func (t Target) bitwise() bool {
+	return t.atLeast(bitwiseVersion)
+}
+
+// binaryStrings доступна ли ПолучитьСтрокуИзДвоичныхДанных. Без нее строки кодируются числами фиксированной длины
+func (t Target) binaryStrings() bool {
+	return t.atLeast(binaryStringsVersion)
+}
+
+// eval можно ли использовать Выполнить()/Вычислить() где-либо в модуле
+func (t Target) eval() bool {
+	return !t.SafeMode && !t.hasClient(ClientMobile)
+}
+
+// parseVersion разбирает версию вида 8.3.10 или 8.3.10.2561
+func parseVersion(version string) ([4]int, error) {
+	var result [4]int
+
+	parts := strings.Split(strings.TrimSpace(version), ".")
+	if len(parts) < 2 || len(parts) > len(result) {
+		return result, errors.Errorf("invalid platform version %q", version)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return result, errors.Errorf("invalid platform version %q", version)
+		}
+		result[i] = n
+	}
+
+	return result, nil
+}
+
+// evalAllowed можно ли спрятать код текущего метода в Выполнить()/Вычислить(). Для веб-клиента
+// код, который может выполняться на клиенте (или контекст которого неизвестен), не прячется
+func (c *Obfuscator) evalAllowed() bool {
+	target := c.conf.Target
+	if !target.eval() {
+		return false
+	}
+	if !target.hasClient(ClientWeb) || c.current == nil {
+		return true
+	}
+
+	contexts := c.executionContext(c.current.Directive)
+	if contexts == nil {
+		return false
+	}
+	for _, ctx := range contexts {
+		if ctx == ContextClient {
+			return false
+		}
+	}
+
+	return true
+}
+
+// targetPasses убирает проходы, которые не работают на целевой платформе
+func (c *Obfuscator) targetPasses(names []string) []string {
+	target := c.conf.Target
+
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		switch {
+		case name == PassEval && !target.eval():
+			c.report(SeverityInfo, DiagTarget, nil, "Выполнить()/Вычислить() are not available on the target platform, eval pass is disabled")
+		case name == PassEval && target.hasClient(ClientWeb):
+			c.report(SeverityInfo, DiagTarget, nil, "Выполнить()/Вычислить() are not used in client code for web client")
+			result = append(result, name)
+		default:
+			result = append(result, name)
+		}
+	}
+
+	return result
+}
+
+// decodeChar выражение декодера для кода символа: исключающее ИЛИ с ключом через побитовые функции
+// или, если они недоступны, вычитание ключа. Должно соответствовать obfuscateString
+func (c *Obfuscator) decodeChar(code, key ast.VarStatement) ast.Statement {
+	if !c.conf.Target.bitwise() {
+		return c.hideValue(&ast.ExpStatement{Operation: ast.OpMinus, Left: code, Right: key})
+	}
+
+	return c.hideValue(ast.MethodStatement{
+		Name: "ПобитовоеИли",
+		Param: ast.ExprStatements{Statements: ast.Statements{
+			c.hideValue(ast.MethodStatement{
+				Name:  "ПобитовоеИНе",
+				Param: ast.ExprStatements{Statements: ast.Statements{code, key}},
+			}),
+			c.hideValue(ast.MethodStatement{
+				Name:  "ПобитовоеИНе",
+				Param: ast.ExprStatements{Statements: ast.Statements{key, c.hideValue(code)}},
+			}),
+		}},
+	})
+}
@@ -0,0 +1,87 @@
+package obfuscator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTargetVersion(t *testing.T) {
+	assert.True(t, Target{}.bitwise())
+	assert.True(t, Target{PlatformVersion: "8.3.11"}.bitwise())
+	assert.True(t, Target{PlatformVersion: "8.3.24.1548"}.bitwise())
+	assert.False(t, Target{PlatformVersion: "8.3.10.2561"}.bitwise())
+	assert.False(t, Target{PlatformVersion: "8.2"}.bitwise())
+
+	assert.Error(t, Target{PlatformVersion: "8.3.x"}.validate())
+	assert.Error(t, Target{PlatformVersion: "8"}.validate())
+	assert.Error(t, Target{Clients: []ClientType{"tablet"}}.validate())
+	assert.NoError(t, Target{PlatformVersion: "8.3.10", Clients: []ClientType{ClientWeb, ClientMobile}}.validate())
+}
+
+func TestTargetDecoder(t *testing.T) {
+	obf := NewObfuscatory(context.Background(), Config{Target: Target{PlatformVersion: "8.3.10"}})
+	// без побитовых функций к коду символа прибавляется ключ, а не исключающее ИЛИ
+	assert.Equal(t, "0KA=", obf.obfuscateString("А", 16))
+	assert.Equal(t, "0IA=", NewObfuscatory(context.Background(), Config{}).obfuscateString("А", 16))
+
+	result, err := obf.ObfuscateResult(`Процедура Тест()
+	Сообщить("Привет");
+КонецПроцедуры`, ModuleInfo{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NotContains(t, result.Code, "Побитов")
+}
+
+func TestTargetEval(t *testing.T) {
+	code := `&НаКлиенте
+Процедура НаКлиенте()
+	Сообщить(Строка(1));
+КонецПроцедуры
+
+&НаСервере
+Процедура НаСервере()
+	Сообщить(Строка(1));
+КонецПроцедуры`
+
+	conf := Config{RepExpByEval: true, Intensity: Intensity{Eval: Level{Probability: ptr(1.0)}}, Target: Target{SafeMode: true}}
+	result, err := NewObfuscatory(context.Background(), conf).ObfuscateResult(code, ModuleInfo{Kind: ModuleForm})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NotContains(t, result.Code, "Выполнить(")
+	assert.Contains(t, result.Diagnostics, Diagnostic{
+		Severity: SeverityInfo,
+		Code:     DiagTarget,
+		Message:  "Выполнить()/Вычислить() are not available on the target platform, eval pass is disabled",
+	})
+
+	// в веб-клиенте код прячется только на сервере
+	conf.Target = Target{Clients: []ClientType{ClientThin, ClientWeb}}
+	obf := NewObfuscatory(context.Background(), conf)
+	result, err = obf.ObfuscateResult(code, ModuleInfo{Kind: ModuleForm})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	server := strings.Index(result.Code, "НаСервере()")
+	if !assert.Greater(t, server, 0) {
+		return
+	}
+	end := server + strings.Index(result.Code[server:], "КонецПроцедуры")
+
+	assert.NotContains(t, result.Code[:server], "Выполнить(")
+	assert.Contains(t, result.Code[server:end], "Выполнить(")
+}
+
+func TestTargetCompatibilityMode(t *testing.T) {
+	// версия платформы новее, но режим совместимости конфигурации ограничивает возможности
+	assert.False(t, Target{PlatformVersion: "8.3.24", CompatibilityMode: "8.3.10"}.bitwise())
+	assert.True(t, Target{CompatibilityMode: "8.3.11"}.bitwise())
+	assert.Error(t, Target{CompatibilityMode: "Версия8_3_10"}.validate())
+}
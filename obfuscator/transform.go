@@ -0,0 +1,341 @@
+package obfuscator
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+	"github.com/pkg/errors"
+)
+
+// имена встроенных проходов
+const (
+	PassStrings    = "strings"
+	PassTernary    = "ternary"
+	PassEval       = "eval"
+	PassGoto       = "goto"
+	PassConditions = "conditions"
+	PassGarbage    = "garbage"
+	PassCallStack  = "callstack"
+)
+
+// Transform отдельный проход обфускации, работает со всем модулем
+type Transform interface {
+	// Name уникальное имя прохода, по нему проход указывается в Config.Passes
+	Name() string
+	// Apply применяет проход к модулю
+	Apply(env *TransformEnv, module *ast.ModuleStatement) error
+}
+
+// RandomSource источник случайных чисел, Int возвращает число из [min, max)
+type RandomSource interface {
+	Int(min, max int) int
+}
+
+// NameGenerator генератор случайных идентификаторов
+type NameGenerator interface {
+	Name(length int) string
+}
+
+// PredicateGenerator генератор заведомо истинных и заведомо ложных условий
+type PredicateGenerator interface {
+	True() *ast.ExpStatement
+	False() *ast.ExpStatement
+}
+
+// TransformEnv то, что доступно проходу во время работы
+type TransformEnv struct {
+	Random     RandomSource
+	Names      NameGenerator
+	Predicates PredicateGenerator
+	Conf       Config
+	Module     ModuleInfo
+
+	obf *Obfuscator
+}
+
+// AddFunction добавляет в модуль служебную функцию, такие функции не обрабатываются другими проходами
+func (e *TransformEnv) AddFunction(f *ast.FunctionOrProcedure) {
+	e.obf.addFunction(f)
+}
+
+// Allowed можно ли применять проход к методу с учетом аннотаций в исходном коде
+func (e *TransformEnv) Allowed(f *ast.FunctionOrProcedure, pass string) bool {
+	return e.obf.allowed(f, pass)
+}
+
+// KeepName нужно ли сохранить имя метода
+func (e *TransformEnv) KeepName(f *ast.FunctionOrProcedure) bool {
+	return e.obf.methodSettings(f).keepName
+}
+
+// IsAsync объявлен ли метод как Асинх. Асинхронные методы с Ждать в дерево не попадают, они выводятся без изменений
+func (e *TransformEnv) IsAsync(f *ast.FunctionOrProcedure) bool {
+	return e.obf.isAsync(f)
+}
+
+// NewLabel метка с уникальным в модуле именем. Результат обфускации проверяется, повторяющиеся метки в методе - ошибка
+func (e *TransformEnv) NewLabel() *ast.GoToLabelStatement {
+	return e.obf.newLabel()
+}
+
+// Report добавляет диагностику к результату, например о коде, который проход не смог преобразовать
+func (e *TransformEnv) Report(severity Severity, code string, f *ast.FunctionOrProcedure, message string) {
+	e.obf.report(severity, code, f, "%s", message)
+}
+
+// Count учитывает изменение текущего прохода в отчете об обфускации
+func (e *TransformEnv) Count() {
+	e.obf.count(e.obf.pass)
+}
+
+// IsHot помечен ли метод как нагруженный (аннотация obfuscate:hot или Config.HotMethods).
+// Встроенные проходы, замедляющие выполнение, к таким методам не применяются
+func (e *TransformEnv) IsHot(f *ast.FunctionOrProcedure) bool {
+	return e.obf.methodSettings(f).hot
+}
+
+// Print возвращает текст конструкции без переносов
+func (e *TransformEnv) Print(stm ast.Statement) string {
+	return e.obf.a.PrintStatementWithConf(stm, ast.PrintConf{})
+}
+
+var (
+	transformsMx sync.RWMutex
+	transforms   = map[string]Transform{}
+)
+
+// builtinPasses встроенные проходы в порядке по умолчанию
+var builtinPasses = []string{PassRename, PassVariables, PassStrings, PassTernary, PassEval, PassGoto, PassExceptions, PassConditions, PassGarbage, PassCallStack}
+
+func init() {
+	for _, name := range builtinPasses {
+		transforms[name] = &walkTransform{name: name}
+	}
+
+	transforms[PassRename] = renameTransform{}
+	transforms[PassVariables] = variablesTransform{}
+}
+
+// RegisterTransform регистрирует проход, после чего его можно указывать в Config.Passes.
+// Проход с уже существующим именем заменяет предыдущий
+func RegisterTransform(t Transform) {
+	transformsMx.Lock()
+	defer transformsMx.Unlock()
+
+	transforms[t.Name()] = t
+}
+
+// LookupTransform возвращает зарегистрированный проход по имени
+func LookupTransform(name string) (Transform, bool) {
+	transformsMx.RLock()
+	defer transformsMx.RUnlock()
+
+	t, ok := transforms[name]
+	return t, ok
+}
+
+// Transforms имена всех зарегистрированных проходов
+func Transforms() []string {
+	transformsMx.RLock()
+	defer transformsMx.RUnlock()
+
+	result := make([]string, 0, len(transforms))
+	for name := range transforms {
+		result = append(result, name)
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// walkTransform встроенный проход, обходит все методы модуля
+type walkTransform struct {
+	name string
+}
+
+func (w *walkTransform) Name() string {
+	return w.name
+}
+
+func (w *walkTransform) Apply(env *TransformEnv, module *ast.ModuleStatement) error {
+	c := env.obf
+
+	defaultIntensity := c.intensity
+	defer func() { c.intensity, c.current = defaultIntensity, nil }()
+
+	c.loopItems = c.collectLoopItems(module)
+
+	module.Walk(func(root *ast.FunctionOrProcedure, parentStm, stm *ast.Statement) {
+		// код основной программы обрабатывается ниже
+		if root == nil || c.isGenerated(root) || !c.allowed(root, w.name) {
+			return
+		}
+
+		c.intensity, c.current = c.methodSettings(root).intensity, root
+		c.walkStep(root, parentStm, stm)
+	})
+
+	if main := c.mainProgram(module); main != nil {
+		c.intensity, c.current = defaultIntensity, main
+		ast.StatementWalk(main, main.Body, func(_ *ast.FunctionOrProcedure, parentStm, stm *ast.Statement) {
+			c.walkStep(main, parentStm, stm)
+		})
+
+		var stm ast.Statement = main
+		c.walkStep(main, nil, &stm)
+		c.setMainProgram(module, main)
+	}
+
+	return nil
+}
+
+// pipeline порядок проходов, если Config.Passes не заполнен, то он определяется флагами.
+// В конец добавляются проходы, которые включены только аннотациями
+func (c *Obfuscator) pipeline() ([]Transform, error) {
+	names := c.conf.Passes
+	if len(names) == 0 {
+		names = c.conf.defaultPasses()
+	}
+
+	c.basePasses = names
+	used := toSet(names)
+	for _, name := range c.annotatedPasses() {
+		if _, ok := used[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	names = c.targetPasses(names)
+	c.activePasses = toSet(names)
+
+	result := make([]Transform, 0, len(names))
+	for _, name := range names {
+		t, ok := LookupTransform(name)
+		if !ok {
+			return nil, errors.Errorf("unknown transform %q", name)
+		}
+
+		result = append(result, t)
+	}
+
+	return result, nil
+}
+
+func (c *Obfuscator) runPipeline(module *ast.ModuleStatement) error {
+	passes, err := c.pipeline()
+	if err != nil {
+		return err
+	}
+
+	env := &TransformEnv{
+		Random:     cryptoRandom{c},
+		Names:      nameGenerator{c},
+		Predicates: predicateGenerator{c},
+		Conf:       c.conf,
+		Module:     c.module,
+		obf:        c,
+	}
+
+	for _, t := range passes {
+		c.pass = t.Name()
+		if err := t.Apply(env, module); err != nil {
+			return errors.Wrapf(err, "transform %q", t.Name())
+		}
+	}
+
+	c.pass = ""
+	return nil
+}
+
+func (conf *Config) defaultPasses() []string {
+	var result []string
+	for _, p := range []struct {
+		enabled bool
+		name    string
+	}{
+		{conf.RenameMethods, PassRename},
+		{conf.RenameVariables, PassVariables},
+		{conf.HideString, PassStrings},
+		{conf.RepExpByTernary, PassTernary},
+		{conf.RepExpByEval, PassEval},
+		{conf.RepLoopByGoto, PassGoto},
+		{conf.ExceptionFlow, PassExceptions},
+		{conf.ChangeConditions, PassConditions},
+		{conf.AppendGarbage, PassGarbage},
+		{conf.CallStackHell, PassCallStack},
+	} {
+		if p.enabled {
+			result = append(result, p.name)
+		}
+	}
+
+	return result
+}
+
+// is выполняется ли сейчас указанный проход. В режиме Config.Performance проходы, замедляющие выполнение,
+// не применяются к коду внутри циклов, Выполнить()/Вычислить() не используются там, где недоступны на Config.Target
+func (c *Obfuscator) is(pass string) bool {
+	switch {
+	case c.pass != pass:
+		return false
+	case c.conf.Performance && c.hot && costly(pass):
+		return false
+	case pass == PassEval:
+		return c.evalAllowed()
+	default:
+		return true
+	}
+}
+
+// addFunction добавляет функцию после методов модуля, перед кодом основной программы.
+// Функция объявляется в тех же условиях препроцессора, что и метод, для которого она создана
+func (c *Obfuscator) addFunction(f *ast.FunctionOrProcedure) {
+	c.generated[f.Name] = struct{}{}
+	c.generatedCount++
+	if c.current != nil && c.placement != nil {
+		c.placement[f] = conditions(c.placement[c.current])
+	}
+
+	body, i := c.a.ModuleStatement.Body, 0
+	for j, stm := range body {
+		if _, ok := stm.(*ast.FunctionOrProcedure); ok {
+			i = j + 1
+		}
+	}
+
+	c.a.ModuleStatement.Body = append(append(append(ast.Statements{}, body[:i]...), f), body[i:]...)
+}
+
+func (c *Obfuscator) isGenerated(f *ast.FunctionOrProcedure) bool {
+	_, ok := c.generated[f.Name]
+	return ok
+}
+
+type cryptoRandom struct {
+	c *Obfuscator
+}
+
+func (r cryptoRandom) Int(min, max int) int {
+	return int(r.c.random(min, max))
+}
+
+type nameGenerator struct {
+	c *Obfuscator
+}
+
+func (n nameGenerator) Name(length int) string {
+	return n.c.randomString(length)
+}
+
+type predicateGenerator struct {
+	c *Obfuscator
+}
+
+func (p predicateGenerator) True() *ast.ExpStatement {
+	return p.c.convStrExpToExpStatement(<-p.c.trueCondition)
+}
+
+func (p predicateGenerator) False() *ast.ExpStatement {
+	return p.c.convStrExpToExpStatement(<-p.c.falseCondition)
+}
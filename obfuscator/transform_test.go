@@ -0,0 +1,91 @@
+package obfuscator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+type testTransform struct {
+	calls int
+}
+
+func (r *testTransform) Name() string {
+	return "test-custom"
+}
+
+func (r *testTransform) Apply(env *TransformEnv, module *ast.ModuleStatement) error {
+	r.calls++
+
+	for _, item := range module.Body {
+		if f, ok := item.(*ast.FunctionOrProcedure); ok && f.Name == "Команда1НаСервере" {
+			f.Name = env.Names.Name(15)
+		}
+	}
+
+	return nil
+}
+
+func TestCustomTransform(t *testing.T) {
+	code := `&НаСервереБезКонтекста
+			Функция Команда1НаСервере()
+				Сообщить("тест");
+			 КонецФункции`
+
+	custom := &testTransform{}
+	RegisterTransform(custom)
+
+	obf := NewObfuscatory(context.Background(), Config{Passes: []string{PassStrings, custom.Name(), PassStrings}})
+	obCode, err := obf.Obfuscate(code)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, custom.calls)
+		assert.NotContains(t, obCode, "Команда1НаСервере")
+		assert.NotContains(t, obCode, `"тест"`)
+	}
+
+	obf = NewObfuscatory(context.Background(), Config{Passes: []string{"unknown"}})
+	_, err = obf.Obfuscate(code)
+	assert.Error(t, err)
+}
+
+func TestDefaultPasses(t *testing.T) {
+	conf := Config{HideString: true, RepLoopByGoto: true, CallStackHell: true}
+	assert.Equal(t, []string{PassStrings, PassGoto, PassCallStack}, conf.defaultPasses())
+
+	for _, name := range conf.defaultPasses() {
+		_, ok := LookupTransform(name)
+		assert.True(t, ok, name)
+	}
+}
+
+func TestAppendGarbagePipeline(t *testing.T) {
+	// мусор в сгенерированный код добавляется по конвейеру проходов, а не по флагу AppendGarbage
+	obf := NewObfuscatory(context.Background(), Config{
+		Passes:    []string{PassStrings, PassGarbage},
+		Intensity: Intensity{Garbage: Level{Probability: ptr(1.0)}},
+	})
+	_, err := obf.pipeline()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var body ast.Statements
+	obf.appendGarbage(&body)
+	assert.NotEmpty(t, body)
+
+	obf = NewObfuscatory(context.Background(), Config{
+		AppendGarbage: true,
+		Passes:        []string{PassStrings},
+		Intensity:     Intensity{Garbage: Level{Probability: ptr(1.0)}},
+	})
+	_, err = obf.pipeline()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	body = nil
+	obf.appendGarbage(&body)
+	assert.Empty(t, body)
+}
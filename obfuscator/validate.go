@@ -0,0 +1,213 @@
+package obfuscator
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+	"github.com/pkg/errors"
+)
+
+// evalCode код, спрятанный в строку Выполнить() (оператор) или Вычислить() (выражение)
+type evalCode struct {
+	code       string
+	expression bool
+}
+
+// addEval запоминает код, спрятанный в строку, для проверки результата
+func (c *Obfuscator) addEval(code string, expression bool) {
+	c.evals = append(c.evals, evalCode{code: code, expression: expression})
+	c.count(PassEval)
+}
+
+// labelScope метки блока и внешних блоков
+type labelScope struct {
+	labels map[string]struct{}
+	parent *labelScope
+	try    bool // блок Попытка или Исключение
+}
+
+// validateOutput проверяет результат перед возвратом: код и строки Выполнить()/Вычислить() разбираются,
+// в каждом методе метки уникальны, а Перейти ведет к существующей метке своего или внешнего блока
+func (c *Obfuscator) validateOutput(result string) error {
+	if findValue(reflect.ValueOf(c.a.ModuleStatement.Body), isEmptyExp) {
+		return errors.New("empty expression in generated code")
+	}
+
+	for _, eval := range c.evals {
+		code := eval.code
+		if eval.expression {
+			code = "_ = " + code + ";"
+		}
+		if err := ast.NewAST(code).Parse(); err != nil {
+			return errors.Wrapf(err, "eval string %q does not parse", eval.code)
+		}
+	}
+
+	pre, err := parsePreprocessor(result)
+	if err != nil {
+		return errors.Wrap(err, "preprocessor error")
+	}
+
+	out := ast.NewAST(pre.source)
+	if err := out.Parse(); err != nil {
+		return errors.Wrap(err, "obfuscated code does not parse")
+	}
+
+	var main ast.Statements
+	for _, stm := range out.ModuleStatement.Body {
+		f, ok := stm.(*ast.FunctionOrProcedure)
+		if !ok {
+			main = append(main, stm)
+			continue
+		}
+		if err := validateJumps(f.Body); err != nil {
+			return errors.Wrapf(err, "method %s", f.Name)
+		}
+	}
+
+	return errors.Wrap(validateJumps(main), "main program")
+}
+
+// isEmptyExp выражение без операндов, так выглядит результат неудачного convStrExpToExpStatement
+func isEmptyExp(stm ast.Statement) bool {
+	exp, ok := stm.(*ast.ExpStatement)
+	return ok && exp != nil && exp.Left == nil && exp.Right == nil
+}
+
+// validateJumps проверяет метки и переходы метода
+func validateJumps(body ast.Statements) error {
+	labels := map[string]struct{}{}
+	if err := collectLabels(body, labels); err != nil {
+		return err
+	}
+
+	return checkJumps(body, nil, false, labels)
+}
+
+func collectLabels(body ast.Statements, labels map[string]struct{}) error {
+	for _, stm := range body {
+		if name, ok := labelName(stm); ok {
+			if _, dup := labels[strings.ToLower(name)]; dup {
+				return errors.Errorf("duplicate label ~%s", name)
+			}
+			labels[strings.ToLower(name)] = struct{}{}
+		}
+
+		for _, nested := range nestedBlocks(stm) {
+			if err := collectLabels(nested, labels); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkJumps платформа не позволяет переходить внутрь Если, циклов и Попытка и выходить из Попытка/Исключение
+func checkJumps(body ast.Statements, parent *labelScope, try bool, all map[string]struct{}) error {
+	scope := &labelScope{labels: map[string]struct{}{}, parent: parent, try: try}
+	for _, stm := range body {
+		if name, ok := labelName(stm); ok {
+			scope.labels[strings.ToLower(name)] = struct{}{}
+		}
+	}
+
+	for _, stm := range body {
+		if target, ok := gotoTarget(stm); ok {
+			if err := scope.resolve(target, all); err != nil {
+				return err
+			}
+			continue
+		}
+
+		_, isTry := deref(stm).(ast.TryStatement)
+		for _, nested := range nestedBlocks(stm) {
+			if err := checkJumps(nested, scope, isTry, all); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *labelScope) resolve(name string, all map[string]struct{}) error {
+	lower := strings.ToLower(name)
+	for scope := s; scope != nil; scope = scope.parent {
+		if _, ok := scope.labels[lower]; ok {
+			return nil
+		}
+		if scope.try {
+			return errors.Errorf("Перейти ~%s leaves Попытка block", name)
+		}
+	}
+
+	if _, ok := all[lower]; ok {
+		return errors.Errorf("Перейти ~%s jumps into nested block", name)
+	}
+
+	return errors.Errorf("label ~%s not found", name)
+}
+
+func labelName(stm ast.Statement) (string, bool) {
+	switch v := stm.(type) {
+	case ast.GoToLabelStatement:
+		return v.Name, true
+	case *ast.GoToLabelStatement:
+		return v.Name, v != nil
+	}
+
+	return "", false
+}
+
+func gotoTarget(stm ast.Statement) (string, bool) {
+	switch v := stm.(type) {
+	case ast.GoToStatement:
+		if v.Label != nil {
+			return v.Label.Name, true
+		}
+	case *ast.GoToStatement:
+		if v != nil && v.Label != nil {
+			return v.Label.Name, true
+		}
+	}
+
+	return "", false
+}
+
+// nestedBlocks вложенные блоки конструкции, в отличие от eachBody работает и с узлами-значениями
+func nestedBlocks(stm ast.Statement) []ast.Statements {
+	v := reflect.ValueOf(stm)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var result []ast.Statements
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+		if body, ok := v.Field(i).Interface().(ast.Statements); ok {
+			result = append(result, body)
+		}
+	}
+
+	return result
+}
+
+// deref узел-значение, указатели на структуры разыменовываются
+func deref(stm ast.Statement) ast.Statement {
+	v := reflect.ValueOf(stm)
+	if v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Struct {
+		return v.Elem().Interface()
+	}
+
+	return stm
+}
@@ -0,0 +1,136 @@
+package obfuscator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateJumps(t *testing.T) {
+	start, end := &ast.GoToLabelStatement{Name: "start"}, &ast.GoToLabelStatement{Name: "end"}
+	inner := &ast.GoToLabelStatement{Name: "inner"}
+
+	// переход из вложенного блока к метке внешнего
+	assert.NoError(t, validateJumps(ast.Statements{
+		start,
+		&ast.IfStatement{Expression: true, TrueBlock: ast.Statements{ast.GoToStatement{Label: end}}},
+		ast.GoToStatement{Label: start},
+		end,
+	}))
+
+	assert.EqualError(t, validateJumps(ast.Statements{start, start}), "duplicate label ~start")
+	assert.EqualError(t, validateJumps(ast.Statements{ast.GoToStatement{Label: end}}), "label ~end not found")
+	assert.EqualError(t, validateJumps(ast.Statements{
+		ast.GoToStatement{Label: inner},
+		&ast.LoopStatement{WhileExpr: true, Body: ast.Statements{inner}},
+	}), "Перейти ~inner jumps into nested block")
+	assert.EqualError(t, validateJumps(ast.Statements{
+		&ast.TryStatement{Catch: ast.Statements{ast.GoToStatement{Label: end}}},
+		end,
+	}), "Перейти ~end leaves Попытка block")
+	// метки разных блоков одного метода тоже не должны совпадать
+	assert.EqualError(t, validateJumps(ast.Statements{
+		&ast.IfStatement{Expression: true, TrueBlock: ast.Statements{&ast.GoToLabelStatement{Name: "L"}}},
+		&ast.GoToLabelStatement{Name: "l"},
+	}), "duplicate label ~l")
+}
+
+func TestValidateOutput(t *testing.T) {
+	obf := NewObfuscatory(context.Background(), Config{})
+	_, err := obf.Obfuscate(`Процедура Тест()
+	Сообщить(1);
+КонецПроцедуры`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	obf.evals = []evalCode{{code: "Сообщить(1"}}
+	assert.Error(t, obf.validateOutput(""))
+
+	obf.evals = []evalCode{{code: "1 + 2", expression: true}}
+	assert.NoError(t, obf.validateOutput(""))
+
+	obf.evals = nil
+	obf.a.ModuleStatement.Body = append(obf.a.ModuleStatement.Body, new(ast.ExpStatement))
+	assert.EqualError(t, obf.validateOutput(""), "empty expression in generated code")
+}
+
+type brokenJumpTransform struct{}
+
+func (brokenJumpTransform) Name() string {
+	return "test-broken-jump"
+}
+
+func (brokenJumpTransform) Apply(_ *TransformEnv, module *ast.ModuleStatement) error {
+	for _, item := range module.Body {
+		if f, ok := item.(*ast.FunctionOrProcedure); ok {
+			f.Body = append(f.Body, ast.GoToStatement{Label: &ast.GoToLabelStatement{Name: "нетметки"}})
+		}
+	}
+
+	return nil
+}
+
+func TestValidationDiagnostic(t *testing.T) {
+	RegisterTransform(brokenJumpTransform{})
+
+	obf := NewObfuscatory(context.Background(), Config{Passes: []string{brokenJumpTransform{}.Name()}})
+	result, err := obf.ObfuscateResult(`Процедура Тест()
+	Сообщить(1);
+КонецПроцедуры`, ModuleInfo{})
+	if !assert.Error(t, err) {
+		return
+	}
+
+	assert.True(t, result.HasErrors())
+	if assert.Len(t, result.Diagnostics, 1) {
+		assert.Equal(t, SeverityError, result.Diagnostics[0].Severity)
+		assert.Equal(t, DiagValidation, result.Diagnostics[0].Code)
+	}
+}
+
+func TestObfuscateIsValid(t *testing.T) {
+	code := `Функция Тест(Коллекция) Экспорт
+	Результат = 0;
+	Для Каждого Элемент Из Коллекция Цикл
+		Попытка
+			Результат = Результат + Элемент;
+		Исключение
+			Продолжить;
+		КонецПопытки;
+	КонецЦикла;
+	Пока Результат > 100 Цикл
+		Результат = Результат / 2;
+	КонецЦикла;
+	Возврат Строка(Результат) + "!";
+КонецФункции`
+
+	conf, err := PresetParanoid.Config()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for i := 0; i < 10; i++ {
+		obCode, err := NewObfuscatory(context.Background(), conf).Obfuscate(code)
+		assert.NoError(t, err)
+		assert.NoError(t, validateJumps(parseBody(t, obCode)))
+	}
+}
+
+func parseBody(t *testing.T, code string) ast.Statements {
+	a := ast.NewAST(code)
+	if !assert.NoError(t, a.Parse()) {
+		return nil
+	}
+
+	var body ast.Statements
+	for _, stm := range a.ModuleStatement.Body {
+		if f, ok := stm.(*ast.FunctionOrProcedure); ok && f.Name == "Тест" {
+			body = f.Body
+		}
+	}
+
+	return body
+}
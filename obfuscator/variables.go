@@ -0,0 +1,113 @@
+package obfuscator
+
+import (
+	"strings"
+
+	"github.com/LazarenkoA/1c-language-parser/ast"
+)
+
+// PassVariables переименование переменных модуля
+const PassVariables = "variables"
+
+// variablesTransform переименовывает неэкспортные переменные модуля (Перем в начале модуля).
+// Не переименовываются переменные, имя которых встречается в строке (Вычислить("Имя")),
+// и переменные, которые участвуют в обращениях через точку: по дереву нельзя отличить переменную от свойства.
+// В методах, где имя переопределено параметром или локальной переменной, обращения не меняются
+type variablesTransform struct{}
+
+func (variablesTransform) Name() string {
+	return PassVariables
+}
+
+func (variablesTransform) Apply(env *TransformEnv, module *ast.ModuleStatement) error {
+	c := env.obf
+
+	keep := c.referencedVariables(module)
+	for _, name := range c.conf.PreservedNames {
+		keep[strings.ToLower(name)] = struct{}{}
+	}
+
+	newNames := map[string]string{}
+	variables := make(map[string]ast.GlobalVariables, len(module.GlobalVariables))
+	for key, v := range module.GlobalVariables {
+		name := strings.ToLower(v.Var.Name)
+		if _, ok := keep[name]; ok || v.Export {
+			variables[key] = v
+			continue
+		}
+
+		newName := env.Names.Name(c.intensity.Names.Variable)
+		newNames[name] = newName
+		c.varRenames[name] = newName
+		v.Var.Name = newName
+		variables[ast.IF(key == name, strings.ToLower(newName), newName)] = v
+	}
+
+	if len(newNames) == 0 {
+		return nil
+	}
+
+	module.GlobalVariables = variables
+
+	for i, item := range module.Body {
+		names := newNames
+		if f, ok := item.(*ast.FunctionOrProcedure); ok {
+			names = map[string]string{}
+			shadowed := localNames(f)
+			for k, v := range newNames {
+				if _, ok := shadowed[k]; !ok {
+					names[k] = v
+				}
+			}
+		}
+
+		mapStatements(&module.Body[i], func(stm ast.Statement) ast.Statement {
+			if v, ok := stm.(ast.VarStatement); ok {
+				if name, ok := names[strings.ToLower(v.Name)]; ok {
+					v.Name = name
+					return v
+				}
+			}
+
+			return stm
+		})
+	}
+
+	return nil
+}
+
+// referencedVariables имена, которые встречаются в строках, в обращениях через точку и в методах с &ИзменениеИКонтроль
+func (c *Obfuscator) referencedVariables(module *ast.ModuleStatement) map[string]struct{} {
+	result := c.controlledIdents()
+	for _, m := range identStringRe.FindAllStringSubmatch(c.source, -1) {
+		result[strings.ToLower(m[1])] = struct{}{}
+	}
+
+	mapStatements(module, func(stm ast.Statement) ast.Statement {
+		if chain, ok := stm.(ast.CallChainStatement); ok {
+			mapStatements(&chain, func(stm ast.Statement) ast.Statement {
+				if v, ok := stm.(ast.VarStatement); ok {
+					result[strings.ToLower(v.Name)] = struct{}{}
+				}
+				return stm
+			})
+		}
+
+		return stm
+	})
+
+	return result
+}
+
+// localNames параметры и локальные переменные метода
+func localNames(f *ast.FunctionOrProcedure) map[string]struct{} {
+	result := map[string]struct{}{}
+	for _, p := range f.Params {
+		result[strings.ToLower(p.Name)] = struct{}{}
+	}
+	for _, v := range f.ExplicitVariables {
+		result[strings.ToLower(v.Name)] = struct{}{}
+	}
+
+	return result
+}
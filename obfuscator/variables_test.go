@@ -0,0 +1,43 @@
+package obfuscator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMainProgram(t *testing.T) {
+	code := `Перем Счетчик;
+Перем Версия Экспорт;
+
+Процедура Увеличить()
+	Счетчик = Счетчик + 1;
+КонецПроцедуры
+
+Процедура Установить(Счетчик)
+	Счетчик = 10;
+КонецПроцедуры
+
+Счетчик = 0;
+Версия = "1.0.0";
+Сообщить("привет");`
+
+	obf := NewObfuscatory(context.Background(), Config{HideString: true})
+	obCode, err := obf.Obfuscate(code)
+	if assert.NoError(t, err) {
+		assert.NotContains(t, obCode, `"привет"`)
+		assert.NotContains(t, obCode, `"1.0.0"`)
+		// служебные функции объявляются до кода основной программы
+		assert.Greater(t, strings.LastIndex(obCode, "Счетчик"), strings.LastIndex(obCode, "КонецФункции"))
+	}
+
+	obf = NewObfuscatory(context.Background(), Config{RenameVariables: true})
+	obCode, err = obf.Obfuscate(code)
+	if assert.NoError(t, err) {
+		assert.Contains(t, obCode, "Версия")
+		assert.Contains(t, obCode, "Установить(Счетчик)")
+		assert.Equal(t, 2, strings.Count(obCode, "Счетчик"))
+	}
+}